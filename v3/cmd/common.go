@@ -1,20 +1,42 @@
 package cmd
 
 import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"gdcl/v3/fsm"
 	"gdcl/v3/protocol"
 	"gdcl/v3/protocol/dock"
-	"gdcl/v3/protocol/framing"
-	"gdcl/v3/protocol/mnp"
+	"gdcl/v3/protocol/messages"
 	"gdcl/v3/protocol/serial"
+	"gdcl/v3/protocol/tcp"
+	"gdcl/v3/session"
 	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
 )
 
 var (
 	port      string
 	speed     int
+	tcpAddr   string
+	listen    string
+	timeout   time.Duration
+	password  string
+	askPass   bool
+	desktop   string
+	dockTime  time.Duration
 	logSerial bool
 	logMnp    bool
 	logDock   bool
+	logFSM    []string
 )
 
 func logEvent(event protocol.Event) {
@@ -23,33 +45,146 @@ func logEvent(event protocol.Event) {
 		if logSerial {
 			log.Println(event)
 		}
-	case *protocol.MnpEvent:
+	case *protocol.MnpEvent, *protocol.FramingErrorEvent, *protocol.AttentionEvent:
 		if logMnp {
 			log.Println(event)
 		}
 	case *protocol.DockEvent:
 		if logDock {
 			log.Println(event)
+			if message, err := messages.Decode(event.(*protocol.DockEvent)); err == nil {
+				log.Printf("%T %+v", message, message)
+			}
 		}
+	case *protocol.ErrorEvent:
+		log.Println(event)
 	}
 }
 
-func eventLoop(port string, speed int, eventHandler func(event protocol.Event)) {
-	log.Println("Starting event loop")
-	go serial.SerialLoop(port, speed)
-	for {
-		event := <-protocol.Events
-		logEvent(event)
-
-		serial.Process(event)
-		framing.Process(event)
-		mnp.Process(event)
-		dock.Process(event)
-		eventHandler(event)
-
-		if protocol.IsQuitEvent(event) {
-			break
+func addTransportFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&port, "port", "p", "/dev/ttyUSB0", "Serial Port")
+	cmd.Flags().IntVarP(&speed, "speed", "s", 115200, "Serial Speed")
+	cmd.Flags().StringVar(&tcpAddr, "tcp", "", "Connect to host:port instead of a serial port")
+	cmd.Flags().StringVar(&listen, "listen", "",
+		"Wait for a Newton docking over TCP/IP on [host]:port, e.g. :"+strconv.Itoa(tcp.DockPort))
+	cmd.Flags().DurationVar(&timeout, "timeout", 0, "Cancel and disconnect after this long, e.g. 2m")
+	cmd.Flags().StringVar(&password, "password", "", "Password set on the Newton")
+	cmd.Flags().BoolVar(&askPass, "ask-password", false, "Prompt for the password set on the Newton")
+	cmd.Flags().StringVar(&desktop, "desktop", "mac", "Desktop type shown to the Newton, mac or windows")
+	cmd.Flags().DurationVar(&dockTime, "dock-timeout", dock.DefaultTimeout,
+		"How long the Newton waits for gdcl before it disconnects")
+	cmd.Flags().BoolVar(&logSerial, "log-serial", false, "Log the data sent and received on the transport")
+	cmd.Flags().BoolVar(&logMnp, "log-mnp", false, "Log MNP packets")
+	cmd.Flags().BoolVar(&logDock, "log-dock", false, "Log dock commands")
+	cmd.Flags().StringSliceVar(&logFSM, "log-fsm", nil,
+		"Log the state machine steps of all layers, or of the given ones, e.g. --log-fsm=dock,info")
+	cmd.Flags().Lookup("log-fsm").NoOptDefVal = "all"
+}
+
+// logStep logs step if its layer was selected with --log-fsm.
+func logStep(step fsm.Step) {
+	for _, layer := range logFSM {
+		if layer == "all" || layer == step.Machine {
+			log.Println(step)
+			return
 		}
 	}
-	log.Println("Event loop complete")
+}
+
+// readPassword prompts for the Newton's password on the terminal without
+// echoing it. A password piped to stdin is read up to the end of the line.
+func readPassword() (string, error) {
+	fmt.Fprint(os.Stderr, "Newton password: ")
+	if fd := int(os.Stdin.Fd()); term.IsTerminal(fd) {
+		password, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		return string(password), err
+	}
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// newSession returns a session on the transport selected on the command
+// line. The command sets the parts of config specific to it, the password,
+// desktop type and timeout come from the command line.
+func newSession(config dock.Config) *session.Session {
+	config.Password = password
+	config.Timeout = dockTime
+	switch desktop {
+	case "mac":
+		config.DesktopType = dock.DesktopMac
+	case "windows":
+		config.DesktopType = dock.DesktopWindows
+	default:
+		log.Fatalf("Unknown desktop type %q", desktop)
+	}
+	if askPass {
+		var err error
+		if config.Password, err = readPassword(); err != nil {
+			log.Fatalf("Error reading the password: %s", err)
+		}
+	}
+	var s *session.Session
+	switch {
+	case listen != "":
+		s = session.New(tcp.NewServer(listen), config)
+	case tcpAddr != "":
+		s = session.New(tcp.NewClient(tcpAddr), config)
+	default:
+		s = session.New(serial.New(port, speed), config)
+	}
+	s.Trace = logEvent
+	if len(logFSM) > 0 {
+		s.TraceFSM = logStep
+	}
+	return s
+}
+
+// Exit statuses of gdcl
+const (
+	exitFailure = 1 // the session failed or timed out
+	exitNewton  = 2 // the Newton reported an error or canceled
+)
+
+// exitStatus returns the exit status for err.
+func exitStatus(err error) int {
+	var resultErr protocol.ResultError
+	if errors.As(err, &resultErr) || errors.Is(err, protocol.ErrCanceled) {
+		return exitNewton
+	}
+	return exitFailure
+}
+
+// eventLoop runs s until it ends. An interrupt or the --timeout cancels
+// the operation in progress and disconnects, a second interrupt kills gdcl.
+// gdcl exits with a non-zero status if the session failed or timed out.
+func eventLoop(s *session.Session) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			stop()
+			log.Println("Disconnecting:", ctx.Err())
+		case <-done:
+		}
+	}()
+	err := s.Run(ctx)
+	if err == nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		err = ctx.Err()
+	}
+	if err != nil {
+		log.Printf("Error: %s", err)
+		os.Exit(exitStatus(err))
+	}
 }
@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"gdcl/v3/fsm"
+	"gdcl/v3/protocol/dock"
+	"gdcl/v3/protocol/framing"
+	"gdcl/v3/protocol/mnp"
+	"gdcl/v3/protocol/modules/info"
+	"gdcl/v3/protocol/modules/install"
+	"gdcl/v3/session"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// graphs are the transition tables fsm-graph can draw, by layer name.
+var graphs = map[string]fsm.Graph{
+	"framing": framing.Table,
+	"mnp":     mnp.Table,
+	"dock":    dock.Table,
+	"info":    info.Table,
+	"install": install.Table,
+	"client":  session.Table,
+}
+
+func graphNames() []string {
+	var names []string
+	for name := range graphs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+var fsmGraphCmd = &cobra.Command{
+	Use:       "fsm-graph <layer>",
+	Short:     "Print the state machine of a layer in Graphviz DOT format",
+	Long:      "Print the state machine of a layer in Graphviz DOT format. Layers: " + strings.Join(graphNames(), ", "),
+	Args:      cobra.ExactArgs(1),
+	ValidArgs: graphNames(),
+	Run: func(cmd *cobra.Command, args []string) {
+		graph, ok := graphs[args[0]]
+		if !ok {
+			log.Fatalf("Error: unknown layer %q, expected one of %s", args[0], strings.Join(graphNames(), ", "))
+		}
+		if err := graph.WriteDOT(os.Stdout); err != nil {
+			log.Fatalf("Error: %s", err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(fsmGraphCmd)
+}
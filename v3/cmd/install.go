@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"gdcl/v3/protocol/dock"
 	"gdcl/v3/protocol/modules/install"
 	"log"
 	"os"
@@ -10,8 +11,7 @@ import (
 
 func init() {
 	rootCmd.AddCommand(installCmd)
-	installCmd.Flags().StringVarP(&port, "port", "p", "/dev/ttyUSB0", "Serial Port")
-	installCmd.Flags().IntVarP(&speed, "speed", "s", 115200, "Serial Speed")
+	addTransportFlags(installCmd)
 	installCmd.Flags().StringVarP(&file, "file", "f", "", "Serial Port")
 }
 
@@ -25,7 +25,8 @@ var installCmd = &cobra.Command{
 		if err != nil {
 			log.Fatalf("Error installing %s: %s", file, err)
 		}
-		install.PackageData = data
-		eventLoop(port, speed, install.Process)
+		s := newSession(dock.Config{Icons: dock.InstallIcon})
+		s.AddModule(install.New(s.Events(), data))
+		eventLoop(s)
 	},
 }
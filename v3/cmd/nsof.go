@@ -27,7 +27,10 @@ var nsofCmd = &cobra.Command{
 		}
 		if decode {
 			var encoded nsof.Data = data
-			decoded := encoded.Factory()
+			decoded, err := encoded.Factory()
+			if err != nil {
+				log.Fatalf("Error: %s", err)
+			}
 			log.Println(decoded)
 			return
 		}
@@ -0,0 +1,68 @@
+package fsm
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// anyStateNode is the node the transitions of AnyState start from.
+const anyStateNode = "*"
+
+// label returns the edge label of transition: the event, or "else" for a
+// fallback, the guard in brackets and the action after a slash.
+func (table *Table[S, E, A]) label(transition *Transition[S, E, A]) string {
+	label := "else"
+	if !transition.Fallback {
+		label = table.eventName(transition.Event)
+	}
+	if transition.Guard != nil {
+		label += " [" + guardName(transition.Guard) + "]"
+	}
+	var none A
+	if transition.Action != none {
+		label += " / " + table.actionName(transition.Action)
+	}
+	return label
+}
+
+// WriteDOT writes the table as a Graphviz digraph. The initial states are
+// entered from a point, transitions of AnyState start from a node named
+// "*", and transitions which keep the state loop back to their source.
+func (table *Table[S, E, A]) WriteDOT(w io.Writer) error {
+	b := bufio.NewWriter(w)
+	fmt.Fprintf(b, "digraph %q {\n", table.Name)
+	fmt.Fprintf(b, "\trankdir=LR;\n")
+	fmt.Fprintf(b, "\tnode [shape=box];\n")
+	fmt.Fprintf(b, "\tstart [shape=point];\n")
+	for _, state := range table.states() {
+		fmt.Fprintf(b, "\t%q;\n", table.stateName(state))
+	}
+	for i, state := range table.Initial {
+		style := ""
+		if i > 0 {
+			style = " [style=dashed]"
+		}
+		fmt.Fprintf(b, "\tstart -> %q%s;\n", table.stateName(state), style)
+	}
+	for _, transition := range table.Transitions {
+		if transition.AnyState {
+			fmt.Fprintf(b, "\t%q [shape=plaintext];\n", anyStateNode)
+			break
+		}
+	}
+	for i := range table.Transitions {
+		transition := &table.Transitions[i]
+		from := anyStateNode
+		if !transition.AnyState {
+			from = table.stateName(transition.State)
+		}
+		to := from
+		if !transition.KeepState {
+			to = table.stateName(transition.NewState)
+		}
+		fmt.Fprintf(b, "\t%q -> %q [label=%q];\n", from, to, table.label(transition))
+	}
+	fmt.Fprintf(b, "}\n")
+	return b.Flush()
+}
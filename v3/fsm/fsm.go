@@ -1,21 +1,150 @@
 package fsm
 
+import "fmt"
+
 type Transition[S comparable, E comparable, A any] struct {
-	State    S
+	State S
+	// AnyState makes the transition apply in every state. Transitions of
+	// the current state, including its fallback, take precedence.
+	AnyState bool
 	Event    E
+	// Guard, if set, has to return true for the transition to match. It is
+	// called with the payload passed to Machine.Input, nil for Input.
+	Guard    func(payload any) bool
 	Action   A
 	NewState S
+	// KeepState leaves the state unchanged instead of moving to NewState.
+	KeepState bool
+	// Fallback matches events no other transition of the state matches.
 	Fallback bool
 }
 
+// NoTransitionError is returned by Input when no transition matches the
+// state and the event.
+type NoTransitionError struct {
+	State any
+	Event any
+}
+
+func (e *NoTransitionError) Error() string {
+	return fmt.Sprintf("no transition from state %v on %v", e.State, e.Event)
+}
+
+// find returns the transition for event in state. Transitions of the state
+// are tried before any-state transitions, and fallbacks after the other
+// transitions of their kind. Guards are only checked if checkGuards is set.
+func find[S comparable, E comparable, A any](
+	event E, state S, payload any, checkGuards bool, transitions []Transition[S, E, A],
+) *Transition[S, E, A] {
+	for _, pass := range []struct{ anyState, fallback bool }{
+		{false, false}, {false, true}, {true, false}, {true, true},
+	} {
+		for i := range transitions {
+			transition := &transitions[i]
+			if transition.AnyState != pass.anyState || transition.Fallback != pass.fallback {
+				continue
+			}
+			if !transition.AnyState && transition.State != state {
+				continue
+			}
+			if !transition.Fallback && transition.Event != event {
+				continue
+			}
+			if checkGuards && transition.Guard != nil && !transition.Guard(payload) {
+				continue
+			}
+			return transition
+		}
+	}
+	return nil
+}
+
+func (transition *Transition[S, E, A]) next(state S) S {
+	if transition.KeepState {
+		return state
+	}
+	return transition.NewState
+}
+
+// Input returns the action and the new state for event in state. If no
+// transition matches, the state is left unchanged and a
+// *NoTransitionError is returned.
 func Input[S comparable, E comparable, A any](
 	event E, state S, transitions []Transition[S, E, A],
-) (A, S) {
-	for _, transition := range transitions {
-		if transition.State == state &&
-			(transition.Event == event || transition.Fallback) {
-			return transition.Action, transition.NewState
-		}
+) (A, S, error) {
+	if transition := find(event, state, nil, true, transitions); transition != nil {
+		return transition.Action, transition.next(state), nil
+	}
+	var none A
+	return none, state, &NoTransitionError{State: state, Event: event}
+}
+
+// Handles reports whether event in state is matched by a transition other
+// than a fallback, i.e. whether the table expects event in state. Guards
+// are assumed to pass.
+func Handles[S comparable, E comparable, A any](
+	event E, state S, transitions []Transition[S, E, A],
+) bool {
+	transition := find(event, state, nil, false, transitions)
+	return transition != nil && !transition.Fallback
+}
+
+// Machine is a state machine on a transition table which also runs entry
+// and exit actions.
+type Machine[S comparable, E comparable, A comparable] struct {
+	State S
+	Table *Table[S, E, A]
+	// Entry and Exit are run when a transition enters or leaves a state.
+	// A transition which keeps the state runs neither.
+	Entry map[S]func()
+	Exit  map[S]func()
+	// Trace, if set, is called with every step of the machine.
+	Trace func(step Step)
+}
+
+// Input moves the machine on event and returns the action of the
+// transition. payload is passed to the guards. The exit action of the old
+// state and the entry action of the new state run before Input returns.
+func (machine *Machine[S, E, A]) Input(event E, payload any) (A, error) {
+	transition := find(event, machine.State, payload, true, machine.Table.Transitions)
+	if machine.Trace != nil {
+		machine.Trace(machine.Table.step(machine.State, event, transition))
+	}
+	if transition == nil {
+		var none A
+		return none, &NoTransitionError{State: machine.State, Event: event}
+	}
+	if !transition.KeepState {
+		machine.move(transition.NewState)
+	}
+	return transition.Action, nil
+}
+
+// Goto moves the machine to state outside of the transition table, e.g.
+// to start an operation. It runs the exit and entry actions like Input.
+func (machine *Machine[S, E, A]) Goto(state S) {
+	if machine.Trace != nil {
+		machine.Trace(Step{
+			Machine:    machine.Table.Name,
+			State:      machine.Table.stateName(machine.State),
+			Transition: -1,
+			NewState:   machine.Table.stateName(state),
+		})
+	}
+	machine.move(state)
+}
+
+func (machine *Machine[S, E, A]) move(state S) {
+	if exit := machine.Exit[machine.State]; exit != nil {
+		exit()
+	}
+	machine.State = state
+	if entry := machine.Entry[machine.State]; entry != nil {
+		entry()
 	}
-	panic("No transition found")
+}
+
+// Handles reports whether the machine expects event in its current state.
+func (machine *Machine[S, E, A]) Handles(event E) bool {
+	return Handles(event, machine.State, machine.Table.Transitions)
 }
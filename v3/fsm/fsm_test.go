@@ -0,0 +1,189 @@
+package fsm
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func isEven(payload any) bool {
+	n, ok := payload.(int)
+	return ok && n%2 == 0
+}
+
+// precedence has a row of each kind matching event "e" in state "a", in
+// order of precedence.
+var precedence = []Transition[string, string, string]{
+	{State: "a", Event: "e", Action: "state", KeepState: true},
+	{State: "a", Fallback: true, Action: "stateFallback", KeepState: true},
+	{AnyState: true, Event: "e", Action: "anyState", KeepState: true},
+	{AnyState: true, Fallback: true, Action: "anyStateFallback", KeepState: true},
+}
+
+func TestInputPrecedence(t *testing.T) {
+	// The rows are listed in reverse order of precedence, so that the
+	// order in the table does not decide.
+	var transitions []Transition[string, string, string]
+	for i := len(precedence) - 1; i >= 0; i-- {
+		transitions = append(transitions, precedence[i])
+	}
+	for _, want := range []string{"state", "stateFallback", "anyState", "anyStateFallback"} {
+		action, state, err := Input("e", "a", transitions)
+		if err != nil || action != want || state != "a" {
+			t.Errorf("got %q, %q, %v, want %q, \"a\", nil", action, state, err, want)
+		}
+		// Remove the row which matched.
+		for i := range transitions {
+			if transitions[i].Action == want {
+				transitions = append(transitions[:i], transitions[i+1:]...)
+				break
+			}
+		}
+	}
+	_, state, err := Input("e", "a", transitions)
+	var noTransition *NoTransitionError
+	if !errors.As(err, &noTransition) || state != "a" {
+		t.Errorf("got %q, %v, want \"a\" and a NoTransitionError", state, err)
+	}
+}
+
+func TestInputOtherState(t *testing.T) {
+	action, _, err := Input("e", "b", precedence)
+	if err != nil || action != "anyState" {
+		t.Errorf("got %q, %v, want \"anyState\"", action, err)
+	}
+	action, _, err = Input("x", "b", precedence)
+	if err != nil || action != "anyStateFallback" {
+		t.Errorf("got %q, %v, want \"anyStateFallback\"", action, err)
+	}
+}
+
+func TestGuards(t *testing.T) {
+	transitions := []Transition[string, string, string]{
+		{State: "a", Event: "e", Guard: isEven, Action: "even", NewState: "b"},
+		{State: "a", Event: "e", Action: "odd", KeepState: true},
+	}
+	machine := Machine[string, string, string]{State: "a", Table: &Table[string, string, string]{Transitions: transitions}}
+	if action, err := machine.Input("e", 1); err != nil || action != "odd" || machine.State != "a" {
+		t.Errorf("odd payload: got %q, %v in %q", action, err, machine.State)
+	}
+	if action, err := machine.Input("e", 2); err != nil || action != "even" || machine.State != "b" {
+		t.Errorf("even payload: got %q, %v in %q", action, err, machine.State)
+	}
+	// The package level Input passes a nil payload.
+	if action, _, _ := Input("e", "a", transitions); action != "odd" {
+		t.Errorf("nil payload: got %q, want \"odd\"", action)
+	}
+}
+
+func TestHandles(t *testing.T) {
+	transitions := []Transition[string, string, string]{
+		{State: "a", Event: "e", Guard: isEven, NewState: "b"},
+		{State: "a", Fallback: true, KeepState: true},
+		{AnyState: true, Event: "f", KeepState: true},
+	}
+	tests := []struct {
+		event, state string
+		want         bool
+	}{
+		// Guards are assumed to pass.
+		{"e", "a", true},
+		{"f", "b", true},
+		// Fallbacks do not count, and the fallback of a state hides the
+		// any-state transitions.
+		{"x", "a", false},
+		{"f", "a", false},
+		{"e", "b", false},
+	}
+	for _, test := range tests {
+		if got := Handles(test.event, test.state, transitions); got != test.want {
+			t.Errorf("Handles(%q, %q) = %v, want %v", test.event, test.state, got, test.want)
+		}
+	}
+}
+
+// recorder is a machine on the states a and b which records the entry and
+// exit actions and the steps it runs.
+type recorder struct {
+	machine Machine[string, string, string]
+	log     []string
+	steps   []Step
+}
+
+func newRecorder() *recorder {
+	r := &recorder{}
+	record := func(s string) func() { return func() { r.log = append(r.log, s) } }
+	r.machine = Machine[string, string, string]{
+		State: "a",
+		Table: &Table[string, string, string]{
+			Name: "test",
+			Transitions: []Transition[string, string, string]{
+				{State: "a", Event: "go", Action: "move", NewState: "b"},
+				{State: "a", Event: "stay", KeepState: true},
+				{State: "a", Event: "again", NewState: "a"},
+			},
+		},
+		Entry: map[string]func(){"a": record("enter a"), "b": record("enter b")},
+		Exit:  map[string]func(){"a": record("exit a"), "b": record("exit b")},
+		Trace: func(step Step) { r.steps = append(r.steps, step) },
+	}
+	return r
+}
+
+func TestMachineEntryExit(t *testing.T) {
+	tests := []struct {
+		event string
+		want  []string
+	}{
+		{"stay", nil},
+		{"again", []string{"exit a", "enter a"}},
+		{"go", []string{"exit a", "enter b"}},
+	}
+	for _, test := range tests {
+		r := newRecorder()
+		if _, err := r.machine.Input(test.event, nil); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(r.log, test.want) {
+			t.Errorf("%s: got %v, want %v", test.event, r.log, test.want)
+		}
+	}
+}
+
+func TestMachineNoTransition(t *testing.T) {
+	r := newRecorder()
+	_, err := r.machine.Input("unknown", nil)
+	var noTransition *NoTransitionError
+	if !errors.As(err, &noTransition) || r.machine.State != "a" || r.log != nil {
+		t.Errorf("got %v in %q running %v", err, r.machine.State, r.log)
+	}
+}
+
+func TestMachineGoto(t *testing.T) {
+	r := newRecorder()
+	r.machine.Goto("b")
+	if want := []string{"exit a", "enter b"}; r.machine.State != "b" || !reflect.DeepEqual(r.log, want) {
+		t.Errorf("got %q running %v, want \"b\" running %v", r.machine.State, r.log, want)
+	}
+}
+
+func TestMachineTrace(t *testing.T) {
+	r := newRecorder()
+	r.machine.Input("stay", nil)
+	r.machine.Input("unknown", nil)
+	r.machine.Input("go", nil)
+	r.machine.Goto("a")
+	want := []string{
+		"test: a on stay -> a (transition 1)",
+		"test: a on unknown: no transition",
+		"test: a on go -> b (transition 0, action move)",
+		"test: b -> a (goto)",
+	}
+	var got []string
+	for _, step := range r.steps {
+		got = append(got, step.String())
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,166 @@
+package fsm
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"runtime"
+	"strings"
+)
+
+// Graph is a transition table which can be checked and drawn without
+// knowing its state, event and action types.
+type Graph interface {
+	Validate() error
+	WriteDOT(w io.Writer) error
+}
+
+// Table describes a transition table for validation and diagrams.
+type Table[S comparable, E comparable, A comparable] struct {
+	Name        string
+	Transitions []Transition[S, E, A]
+	// Initial lists the states a machine is put in outside of the table:
+	// the state it starts in and states entered with Goto.
+	Initial []S
+	// States, Events and Actions name the values in diagrams and errors.
+	// Values without a name are printed with fmt.
+	States  map[S]string
+	Events  map[E]string
+	Actions map[A]string
+}
+
+func (table *Table[S, E, A]) stateName(state S) string {
+	if name, ok := table.States[state]; ok {
+		return name
+	}
+	return fmt.Sprint(state)
+}
+
+func (table *Table[S, E, A]) eventName(event E) string {
+	if name, ok := table.Events[event]; ok {
+		return name
+	}
+	return fmt.Sprint(event)
+}
+
+func (table *Table[S, E, A]) actionName(action A) string {
+	if name, ok := table.Actions[action]; ok {
+		return name
+	}
+	return fmt.Sprint(action)
+}
+
+// guardName returns the name of the guard function without its package
+// path.
+func guardName(guard func(payload any) bool) string {
+	name := runtime.FuncForPC(reflect.ValueOf(guard).Pointer()).Name()
+	return name[strings.LastIndex(name, "/")+1:]
+}
+
+// states returns all states the table mentions, in order of appearance.
+func (table *Table[S, E, A]) states() []S {
+	var states []S
+	seen := map[S]bool{}
+	add := func(state S) {
+		if !seen[state] {
+			seen[state] = true
+			states = append(states, state)
+		}
+	}
+	for _, state := range table.Initial {
+		add(state)
+	}
+	for _, transition := range table.Transitions {
+		if !transition.AnyState {
+			add(transition.State)
+		}
+		if !transition.KeepState {
+			add(transition.NewState)
+		}
+	}
+	for state := range table.States {
+		add(state)
+	}
+	return states
+}
+
+// reachable returns the states reachable from the initial states.
+func (table *Table[S, E, A]) reachable() map[S]bool {
+	reached := map[S]bool{}
+	queue := append([]S(nil), table.Initial...)
+	for len(queue) > 0 {
+		state := queue[0]
+		queue = queue[1:]
+		if reached[state] {
+			continue
+		}
+		reached[state] = true
+		for _, transition := range table.Transitions {
+			if (transition.AnyState || transition.State == state) && !transition.KeepState {
+				queue = append(queue, transition.NewState)
+			}
+		}
+	}
+	return reached
+}
+
+// shadows reports whether transition a matches every input transition b
+// matches, so that b, coming later, is never used.
+func (table *Table[S, E, A]) shadows(a, b *Transition[S, E, A]) bool {
+	return a.Guard == nil &&
+		a.AnyState == b.AnyState && (a.AnyState || a.State == b.State) &&
+		a.Fallback == b.Fallback && (a.Fallback || a.Event == b.Event)
+}
+
+func (table *Table[S, E, A]) describe(transition *Transition[S, E, A]) string {
+	state := "any state"
+	if !transition.AnyState {
+		state = "state " + table.stateName(transition.State)
+	}
+	if transition.Fallback {
+		return "fallback of " + state
+	}
+	return fmt.Sprintf("%s on %s", state, table.eventName(transition.Event))
+}
+
+// Validate reports unreachable states, states without transitions and
+// transitions which never match because an earlier one takes their input.
+func (table *Table[S, E, A]) Validate() error {
+	var errs []error
+	reached := table.reachable()
+	for _, state := range table.states() {
+		if !reached[state] {
+			errs = append(errs, fmt.Errorf("%s: state %s is unreachable", table.Name, table.stateName(state)))
+			continue
+		}
+		outgoing := false
+		for _, transition := range table.Transitions {
+			if transition.AnyState || transition.State == state {
+				outgoing = true
+				break
+			}
+		}
+		if !outgoing {
+			errs = append(errs, fmt.Errorf("%s: state %s has no transitions", table.Name, table.stateName(state)))
+		}
+	}
+	for j := range table.Transitions {
+		for i := 0; i < j; i++ {
+			if table.shadows(&table.Transitions[i], &table.Transitions[j]) {
+				errs = append(errs, fmt.Errorf("%s: transition %d (%s) duplicates transition %d",
+					table.Name, j, table.describe(&table.Transitions[j]), i))
+				break
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// MustValidate panics if the table is not valid. It is meant to be called
+// from init, so that a broken table shows up when the program starts.
+func (table *Table[S, E, A]) MustValidate() {
+	if err := table.Validate(); err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,100 @@
+package fsm
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	table := &Table[string, string, string]{
+		Name: "good",
+		Transitions: []Transition[string, string, string]{
+			{State: "a", Event: "e", Guard: isEven, NewState: "b"},
+			// Not shadowed: the row above has a guard.
+			{State: "a", Event: "e", KeepState: true},
+			{State: "a", Fallback: true, KeepState: true},
+			{AnyState: true, Event: "e", KeepState: true},
+			{AnyState: true, Fallback: true, KeepState: true},
+			// c is only entered with Goto.
+			{State: "c", Event: "f", NewState: "a"},
+		},
+		Initial: []string{"a", "c"},
+	}
+	if err := table.Validate(); err != nil {
+		t.Errorf("Validate() = %v, want nil", err)
+	}
+}
+
+func TestValidateErrors(t *testing.T) {
+	table := &Table[string, string, string]{
+		Name: "bad",
+		Transitions: []Transition[string, string, string]{
+			{State: "a", Event: "e", NewState: "b"},
+			{State: "a", Event: "f", NewState: "a"},
+			{State: "a", Event: "e", NewState: "a"},
+			{State: "a", Fallback: true, KeepState: true},
+			{State: "a", Fallback: true, NewState: "b"},
+			{State: "c", Event: "e", NewState: "a"},
+		},
+		Initial: []string{"a"},
+		States:  map[string]string{"b": "dead end"},
+	}
+	want := []string{
+		"bad: state dead end has no transitions",
+		"bad: state c is unreachable",
+		"bad: transition 2 (state a on e) duplicates transition 0",
+		"bad: transition 4 (fallback of state a) duplicates transition 3",
+	}
+	err := table.Validate()
+	if err == nil {
+		t.Fatal("Validate() = nil")
+	}
+	if err.Error() != strings.Join(want, "\n") {
+		t.Errorf("Validate() =\n%s\nwant\n%s", err, strings.Join(want, "\n"))
+	}
+}
+
+func TestMustValidate(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("MustValidate did not panic")
+		}
+	}()
+	table := &Table[string, string, string]{Name: "dead end", Initial: []string{"a"}}
+	table.MustValidate()
+}
+
+func TestWriteDOT(t *testing.T) {
+	table := &Table[string, string, string]{
+		Name: "t",
+		Transitions: []Transition[string, string, string]{
+			{State: "a", Event: "e", Guard: isEven, Action: "act", NewState: "b"},
+			{State: "b", Fallback: true, KeepState: true},
+			{AnyState: true, Event: "r", NewState: "a"},
+		},
+		Initial: []string{"a", "b"},
+		States:  map[string]string{"a": "A"},
+		Events:  map[string]string{"r": "reset"},
+	}
+	var b strings.Builder
+	if err := table.WriteDOT(&b); err != nil {
+		t.Fatal(err)
+	}
+	want := `digraph "t" {
+	rankdir=LR;
+	node [shape=box];
+	start [shape=point];
+	"A";
+	"b";
+	start -> "A";
+	start -> "b" [style=dashed];
+	"*" [shape=plaintext];
+	"A" -> "b" [label="e [fsm.isEven] / act"];
+	"b" -> "b" [label="else"];
+	"*" -> "A" [label="reset"];
+}
+`
+	if b.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", b.String(), want)
+	}
+}
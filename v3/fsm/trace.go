@@ -0,0 +1,66 @@
+package fsm
+
+import "fmt"
+
+// Step is one input to a Machine, as passed to its Trace function. The
+// values are given by their names in the machine's Table.
+type Step struct {
+	Machine string
+	State   string
+	// Event is empty when the machine was moved with Goto.
+	Event string
+	// Transition is the index of the matching transition in the table, or
+	// -1 if none matched or the machine was moved with Goto.
+	Transition int
+	// Guard is the guard of the transition, if any.
+	Guard    string
+	Action   string
+	NewState string
+}
+
+func (step Step) String() string {
+	switch {
+	case step.Event == "":
+		return fmt.Sprintf("%s: %s -> %s (goto)", step.Machine, step.State, step.NewState)
+	case step.Transition < 0:
+		return fmt.Sprintf("%s: %s on %s: no transition", step.Machine, step.State, step.Event)
+	}
+	s := fmt.Sprintf("%s: %s on %s -> %s (transition %d", step.Machine, step.State, step.Event, step.NewState, step.Transition)
+	if step.Guard != "" {
+		s += ", guard " + step.Guard
+	}
+	if step.Action != "" {
+		s += ", action " + step.Action
+	}
+	return s + ")"
+}
+
+// step describes the input of event in state, matched by transition.
+func (table *Table[S, E, A]) step(state S, event E, transition *Transition[S, E, A]) Step {
+	step := Step{
+		Machine:    table.Name,
+		State:      table.stateName(state),
+		Event:      table.eventName(event),
+		Transition: -1,
+		NewState:   table.stateName(state),
+	}
+	if transition == nil {
+		return step
+	}
+	for i := range table.Transitions {
+		if &table.Transitions[i] == transition {
+			step.Transition = i
+		}
+	}
+	if transition.Guard != nil {
+		step.Guard = guardName(transition.Guard)
+	}
+	var none A
+	if transition.Action != none {
+		step.Action = table.actionName(transition.Action)
+	}
+	if !transition.KeepState {
+		step.NewState = table.stateName(transition.NewState)
+	}
+	return step
+}
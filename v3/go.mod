@@ -2,11 +2,15 @@ module gdcl/v3
 
 go 1.22.3
 
+require (
+	github.com/spf13/cobra v1.8.1
+	go.bug.st/serial v1.6.2
+	golang.org/x/term v0.0.0-20220722155259-a9ba230a4035
+)
+
 require (
 	github.com/creack/goselect v0.1.2 // indirect
 	github.com/inconshreveable/mousetrap v1.1.0 // indirect
-	github.com/spf13/cobra v1.8.1 // indirect
 	github.com/spf13/pflag v1.0.5 // indirect
-	go.bug.st/serial v1.6.2 // indirect
 	golang.org/x/sys v0.0.0-20220829200755-d48e67d00261 // indirect
 )
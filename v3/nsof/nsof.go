@@ -16,7 +16,7 @@ type Writer interface {
 }
 
 type Reader interface {
-	ReadNSOF(*Data, *ObjectStream) Object
+	ReadNSOF(*Data, *ObjectStream) (Object, error)
 }
 
 type Object interface {
@@ -27,14 +27,42 @@ type Object interface {
 
 type ObjectStream []Object
 
-func (data Data) PeekXLong() int32 {
+var (
+	ErrShortData        = errors.New("data too short")
+	ErrUnknownType      = errors.New("unknown type")
+	ErrUnknownImmediate = errors.New("unknown immediate")
+	ErrBadPrecedent     = errors.New("precedent out of range")
+)
+
+// DecodeError is returned when NSOF data cannot be decoded. Err is one of
+// ErrShortData, ErrUnknownType, ErrUnknownImmediate or ErrBadPrecedent,
+// possibly wrapped.
+type DecodeError struct {
+	Err error
+}
+
+func (e *DecodeError) Error() string {
+	return "nsof: " + e.Err.Error()
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+func (data Data) PeekXLong() (int32, error) {
 	var r int32
 
+	if len(data) < 1 {
+		return 0, ErrShortData
+	}
 	r = int32(data[0])
 	if r > 254 {
+		if len(data) < 5 {
+			return 0, ErrShortData
+		}
 		r = ((int32(data[1])<<8+int32(data[2]))<<8+int32(data[3]))<<8 + int32(data[4])
 	}
-	return r
+	return r, nil
 }
 
 func (data *Data) SkipXLong() {
@@ -45,10 +73,23 @@ func (data *Data) SkipXLong() {
 	}
 }
 
-func (data *Data) DecodeXLong() int32 {
-	r := data.PeekXLong()
+func (data *Data) DecodeXLong() (int32, error) {
+	r, err := data.PeekXLong()
+	if err != nil {
+		return 0, err
+	}
 	data.SkipXLong()
-	return r
+	return r, nil
+}
+
+// next removes the next length bytes from data and returns them.
+func (data *Data) next(length int32) ([]byte, error) {
+	if length < 0 || int(length) > len(*data) {
+		return nil, ErrShortData
+	}
+	r := (*data)[:length]
+	*data = (*data)[length:]
+	return r, nil
 }
 
 func (data *Data) EncodeXLong(value int32) {
@@ -85,16 +126,18 @@ func NewCharacter() *Character {
 	return &Character{Value: 0}
 }
 
-func (character *Character) ReadNSOF(data *Data, objectStream *ObjectStream) Object {
-	value := data.PeekXLong()
+func (character *Character) ReadNSOF(data *Data, objectStream *ObjectStream) (Object, error) {
+	value, err := data.PeekXLong()
+	if err != nil {
+		return nil, err
+	}
 	if value&0xf == 6 {
 		character.Value = uint16(value >> 4)
 		data.SkipXLong()
-		*objectStream = append(*objectStream, character)
 	} else {
 		character = nil
 	}
-	return character
+	return character, nil
 }
 
 func (character *Character) WriteNSOF(data *Data) {
@@ -109,15 +152,17 @@ func (character *Character) String() string {
 type True struct {
 }
 
-func (t *True) ReadNSOF(data *Data, objectStream *ObjectStream) Object {
-	value := data.PeekXLong()
+func (t *True) ReadNSOF(data *Data, objectStream *ObjectStream) (Object, error) {
+	value, err := data.PeekXLong()
+	if err != nil {
+		return nil, err
+	}
 	if value == 0x1a {
 		data.SkipXLong()
-		*objectStream = append(*objectStream, t)
 	} else {
 		t = nil
 	}
-	return t
+	return t, nil
 }
 
 func (t *True) String() string {
@@ -136,15 +181,17 @@ func NewNil() *Nil {
 	return &Nil{}
 }
 
-func (n *Nil) ReadNSOF(data *Data, objectStream *ObjectStream) Object {
-	value := data.PeekXLong()
+func (n *Nil) ReadNSOF(data *Data, objectStream *ObjectStream) (Object, error) {
+	value, err := data.PeekXLong()
+	if err != nil {
+		return nil, err
+	}
 	if value == 0x2 {
 		data.SkipXLong()
-		*objectStream = append(*objectStream, n)
 	} else {
 		n = nil
 	}
-	return n
+	return n, nil
 }
 
 func (n *Nil) String() string {
@@ -159,16 +206,18 @@ type MagicPointer struct {
 	Value int32
 }
 
-func (pointer *MagicPointer) ReadNSOF(data *Data, objectStream *ObjectStream) Object {
-	value := data.PeekXLong()
+func (pointer *MagicPointer) ReadNSOF(data *Data, objectStream *ObjectStream) (Object, error) {
+	value, err := data.PeekXLong()
+	if err != nil {
+		return nil, err
+	}
 	if value&0x3 == 3 {
 		pointer.Value = (value >> 4) & 0xffff
 		data.SkipXLong()
-		*objectStream = append(*objectStream, pointer)
 	} else {
 		pointer = nil
 	}
-	return pointer
+	return pointer, nil
 }
 
 func (pointer *MagicPointer) String() string {
@@ -188,16 +237,18 @@ func NewInteger() *Integer {
 	return &Integer{}
 }
 
-func (integer *Integer) ReadNSOF(data *Data, objectStream *ObjectStream) Object {
-	value := data.PeekXLong()
+func (integer *Integer) ReadNSOF(data *Data, objectStream *ObjectStream) (Object, error) {
+	value, err := data.PeekXLong()
+	if err != nil {
+		return nil, err
+	}
 	if value&0x3 == 0 {
 		integer.Value = value >> 2
 		data.SkipXLong()
-		*objectStream = append(*objectStream, integer)
 	} else {
 		integer = nil
 	}
-	return integer
+	return integer, nil
 }
 
 func (integer *Integer) WriteNSOF(data *Data) {
@@ -209,9 +260,12 @@ func (integer *Integer) String() string {
 	return strconv.Itoa(int(integer.Value))
 }
 
-func NewImmediate(data *Data, objectStream *ObjectStream) Object {
+func NewImmediate(data *Data, objectStream *ObjectStream) (Object, error) {
 	var r Object
-	value := data.PeekXLong()
+	value, err := data.PeekXLong()
+	if err != nil {
+		return nil, err
+	}
 	if value&0x3 == 0 {
 		r = &Integer{value >> 2}
 	} else if value&0x3 == 3 {
@@ -223,13 +277,11 @@ func NewImmediate(data *Data, objectStream *ObjectStream) Object {
 	} else if value == 0x2 {
 		r = &Nil{}
 	}
-	if r != nil {
-		data.SkipXLong()
-		*objectStream = append(*objectStream, r)
-	} else {
-		panic(fmt.Sprintf("Parsing immediate %d not implemented. Data: %x\n", value, (*data)[:10]))
+	if r == nil {
+		return nil, fmt.Errorf("%w %#x", ErrUnknownImmediate, value)
 	}
-	return r
+	data.SkipXLong()
+	return r, nil
 }
 
 type Slot struct {
@@ -245,17 +297,27 @@ func NewFrame() *Frame {
 	return &Frame{}
 }
 
-func (frame *Frame) ReadNSOF(data *Data, objectStream *ObjectStream) Object {
+func (frame *Frame) ReadNSOF(data *Data, objectStream *ObjectStream) (Object, error) {
 	*objectStream = append(*objectStream, frame)
-	elements := data.DecodeXLong()
+	elements, err := data.DecodeXLong()
+	if err != nil {
+		return nil, err
+	}
+	if elements < 0 || int(elements) > len(*data) {
+		return nil, ErrShortData
+	}
 	frame.Slots = make([]Slot, elements)
 	for i := range frame.Slots {
-		frame.Slots[i] = Slot{data.DecodeObject(objectStream), nil}
+		if frame.Slots[i].Key, err = data.decodeObject(objectStream); err != nil {
+			return nil, err
+		}
 	}
 	for i := range frame.Slots {
-		frame.Slots[i].Value = data.DecodeObject(objectStream)
+		if frame.Slots[i].Value, err = data.decodeObject(objectStream); err != nil {
+			return nil, err
+		}
 	}
-	return frame
+	return frame, nil
 }
 
 func (frame *Frame) WriteNSOF(data *Data) {
@@ -309,12 +371,18 @@ type Symbol struct {
 	Value string
 }
 
-func (symbol *Symbol) ReadNSOF(data *Data, objectStream *ObjectStream) Object {
+func (symbol *Symbol) ReadNSOF(data *Data, objectStream *ObjectStream) (Object, error) {
 	*objectStream = append(*objectStream, symbol)
-	length := data.DecodeXLong()
-	symbol.Value = string((*data)[:length])
-	*data = (*data)[length:]
-	return symbol
+	length, err := data.DecodeXLong()
+	if err != nil {
+		return nil, err
+	}
+	value, err := data.next(length)
+	if err != nil {
+		return nil, err
+	}
+	symbol.Value = string(value)
+	return symbol, nil
 }
 
 func (symbol *Symbol) WriteNSOF(data *Data) {
@@ -335,12 +403,20 @@ type PlainArray struct {
 	Objects []Object
 }
 
-func (plainArray *PlainArray) ReadNSOF(data *Data, objectStream *ObjectStream) Object {
+func (plainArray *PlainArray) ReadNSOF(data *Data, objectStream *ObjectStream) (Object, error) {
 	*objectStream = append(*objectStream, plainArray)
-	for length := data.DecodeXLong(); length > 0; length-- {
-		plainArray.Objects = append(plainArray.Objects, data.DecodeObject(objectStream))
+	length, err := data.DecodeXLong()
+	if err != nil {
+		return nil, err
 	}
-	return plainArray
+	for ; length > 0; length-- {
+		object, err := data.decodeObject(objectStream)
+		if err != nil {
+			return nil, err
+		}
+		plainArray.Objects = append(plainArray.Objects, object)
+	}
+	return plainArray, nil
 }
 
 func (plainArray *PlainArray) WriteNSOF(data *Data) {
@@ -374,9 +450,10 @@ type Precedent struct {
 	Reference int32
 }
 
-func (precedent *Precedent) ReadNSOF(data *Data, objectStream *ObjectStream) Object {
-	precedent.Reference = data.DecodeXLong()
-	return precedent
+func (precedent *Precedent) ReadNSOF(data *Data, objectStream *ObjectStream) (Object, error) {
+	var err error
+	precedent.Reference, err = data.DecodeXLong()
+	return precedent, err
 }
 
 func (precedent *Precedent) WriteNSOF(data *Data) {
@@ -396,17 +473,23 @@ type String struct {
 	Value []rune
 }
 
-func (str *String) ReadNSOF(data *Data, objectStream *ObjectStream) Object {
+func (str *String) ReadNSOF(data *Data, objectStream *ObjectStream) (Object, error) {
 	var i int32
 	*objectStream = append(*objectStream, str)
-	length := data.DecodeXLong()
+	length, err := data.DecodeXLong()
+	if err != nil {
+		return nil, err
+	}
+	value, err := data.next(length)
+	if err != nil {
+		return nil, err
+	}
 	chars := make([]uint16, length/2)
 	for i = 0; i < length/2; i++ {
-		chars[i] = uint16((*data)[i*2])*256 + uint16((*data)[i*2+1])
+		chars[i] = uint16(value[i*2])*256 + uint16(value[i*2+1])
 	}
 	str.Value = utf16.Decode(chars)
-	*data = (*data)[length:]
-	return str
+	return str, nil
 }
 
 func (str *String) WriteNSOF(data *Data) {
@@ -431,13 +514,19 @@ type BinaryObject struct {
 	class Object
 }
 
-func (binaryObject *BinaryObject) ReadNSOF(data *Data, objectStream *ObjectStream) Object {
+func (binaryObject *BinaryObject) ReadNSOF(data *Data, objectStream *ObjectStream) (Object, error) {
 	*objectStream = append(*objectStream, binaryObject)
-	length := data.DecodeXLong()
-	binaryObject.class = data.DecodeObject(objectStream)
-	binaryObject.value = (*data)[:length]
-	*data = (*data)[length:]
-	return binaryObject
+	length, err := data.DecodeXLong()
+	if err != nil {
+		return nil, err
+	}
+	if binaryObject.class, err = data.decodeObject(objectStream); err != nil {
+		return nil, err
+	}
+	if binaryObject.value, err = data.next(length); err != nil {
+		return nil, err
+	}
+	return binaryObject, nil
 }
 
 func (binaryObject *BinaryObject) WriteNSOF(data *Data) {
@@ -463,10 +552,13 @@ func NewUnicodeCharacter() *UnicodeCharacter {
 	return &UnicodeCharacter{value: 0}
 }
 
-func (character *UnicodeCharacter) ReadNSOF(data *Data, objectStream *ObjectStream) Object {
-	character.value = uint16((*data)[0])<<8 + uint16((*data)[1])
-	*data = (*data)[2:]
-	return character
+func (character *UnicodeCharacter) ReadNSOF(data *Data, objectStream *ObjectStream) (Object, error) {
+	value, err := data.next(2)
+	if err != nil {
+		return nil, err
+	}
+	character.value = uint16(value[0])<<8 + uint16(value[1])
+	return character, nil
 }
 
 func (character *UnicodeCharacter) WriteNSOF(data *Data) {
@@ -487,14 +579,23 @@ func NewArray() *Array {
 	return &Array{}
 }
 
-func (array *Array) ReadNSOF(data *Data, objectStream *ObjectStream) Object {
+func (array *Array) ReadNSOF(data *Data, objectStream *ObjectStream) (Object, error) {
 	*objectStream = append(*objectStream, array)
-	length := int(data.DecodeXLong())
-	array.class = data.DecodeObject(objectStream)
-	for i := 0; i < length; i++ {
-		array.objects = append(array.objects, data.DecodeObject(objectStream))
+	length, err := data.DecodeXLong()
+	if err != nil {
+		return nil, err
 	}
-	return array
+	if array.class, err = data.decodeObject(objectStream); err != nil {
+		return nil, err
+	}
+	for i := int32(0); i < length; i++ {
+		object, err := data.decodeObject(objectStream)
+		if err != nil {
+			return nil, err
+		}
+		array.objects = append(array.objects, object)
+	}
+	return array, nil
 }
 
 func (array *Array) WriteNSOF(data *Data) {
@@ -521,14 +622,17 @@ func NewSmallRect() *SmallRect {
 	return &SmallRect{}
 }
 
-func (smallRect *SmallRect) ReadNSOF(data *Data, objectStream *ObjectStream) Object {
+func (smallRect *SmallRect) ReadNSOF(data *Data, objectStream *ObjectStream) (Object, error) {
 	*objectStream = append(*objectStream, smallRect)
-	smallRect.top = (*data)[0]
-	smallRect.left = (*data)[1]
-	smallRect.bottom = (*data)[2]
-	smallRect.right = (*data)[3]
-	*data = (*data)[4:]
-	return smallRect
+	value, err := data.next(4)
+	if err != nil {
+		return nil, err
+	}
+	smallRect.top = value[0]
+	smallRect.left = value[1]
+	smallRect.bottom = value[2]
+	smallRect.right = value[3]
+	return smallRect, nil
 }
 
 func (smallRect *SmallRect) WriteNSOF(data *Data) {
@@ -551,8 +655,8 @@ func NewLargeBinary() *LargeBinary {
 	return &LargeBinary{}
 }
 
-func (largeBinary *LargeBinary) ReadNSOF(data *Data, objectStream *ObjectStream) Object {
-	return largeBinary
+func (largeBinary *LargeBinary) ReadNSOF(data *Data, objectStream *ObjectStream) (Object, error) {
+	return largeBinary, nil
 }
 
 func (largeBinary *LargeBinary) WriteNSOF(data *Data) {
@@ -562,52 +666,76 @@ func (largeBinary *LargeBinary) String() string {
 	return fmt.Sprintf("<binary, %d bytes>", len(largeBinary.data))
 }
 
-func (data *Data) DecodeObject(stream *ObjectStream) Object {
+// DecodeObject decodes the next object in data. Errors are of type
+// *DecodeError.
+func (data *Data) DecodeObject(stream *ObjectStream) (Object, error) {
+	object, err := data.decodeObject(stream)
+	if err != nil {
+		return nil, &DecodeError{Err: err}
+	}
+	return object, nil
+}
+
+func (data *Data) decodeObject(stream *ObjectStream) (Object, error) {
 	var object Object
+	if len(*data) < 1 {
+		return nil, ErrShortData
+	}
 	objtype := (*data)[0]
 	*data = (*data)[1:]
 	if objtype == IMMEDIATE {
-		object = NewImmediate(data, stream)
-	} else {
-		switch objtype {
-		case FRAME:
-			object = NewFrame()
-		case SYMBOL:
-			object = NewSymbol()
-		case PLAINARRAY:
-			object = NewPlainArray()
-		case PRECEDENT:
-			object = NewPrecedent()
-		case STRING:
-			object = NewString()
-		case NIL:
-			object = NewNil()
-		case BINARYOBJECT:
-			object = NewBinaryObject()
-		case CHARACTER:
-			object = NewCharacter()
-		case UNICODECHARACTER:
-			object = NewUnicodeCharacter()
-		case ARRAY:
-			object = NewArray()
-		case SMALLRECT:
-			object = NewSmallRect()
-		case LARGEBINARY:
-			object = NewLargeBinary()
-		default:
-			panic(fmt.Sprintf("Parsing type %d not implemented. Data: %x\n", objtype, (*data)[:10]))
+		return NewImmediate(data, stream)
+	}
+	switch objtype {
+	case FRAME:
+		object = NewFrame()
+	case SYMBOL:
+		object = NewSymbol()
+	case PLAINARRAY:
+		object = NewPlainArray()
+	case PRECEDENT:
+		object = NewPrecedent()
+	case STRING:
+		object = NewString()
+	case NIL:
+		// NIL has no payload, unlike the nil immediate
+		return NewNil(), nil
+	case BINARYOBJECT:
+		object = NewBinaryObject()
+	case CHARACTER:
+		object = NewCharacter()
+	case UNICODECHARACTER:
+		object = NewUnicodeCharacter()
+	case ARRAY:
+		object = NewArray()
+	case SMALLRECT:
+		object = NewSmallRect()
+	case LARGEBINARY:
+		object = NewLargeBinary()
+	default:
+		return nil, fmt.Errorf("%w %d", ErrUnknownType, objtype)
+	}
+	if _, err := object.ReadNSOF(data, stream); err != nil {
+		return nil, err
+	}
+	// A precedent refers to an earlier object in the stream by its position.
+	if precedent, ok := object.(*Precedent); ok {
+		if precedent.Reference < 0 || int(precedent.Reference) >= len(*stream) {
+			return nil, fmt.Errorf("%w: %d", ErrBadPrecedent, precedent.Reference)
 		}
-		object.ReadNSOF(data, stream)
+		object = (*stream)[precedent.Reference]
 	}
-	return object
+	return object, nil
 }
 
-func (data Data) Factory() ObjectStream {
+func (data Data) Factory() (ObjectStream, error) {
 	objects := make(ObjectStream, 0, 100)
 	for len(data) > 0 {
-		data.DecodeObject(&objects)
+		if _, err := data.DecodeObject(&objects); err != nil {
+			return objects, err
+		}
 	}
-	return objects
+	return objects, nil
 }
 
 func (stream ObjectStream) Print() {
@@ -0,0 +1,79 @@
+package nsof
+
+import (
+	"errors"
+	"testing"
+)
+
+// twoFrames is [{name: "a", id: 1}, {name: "b", id: 2}, "b"]. The second
+// frame names its slots with precedents and the last element refers to "b",
+// which is object 6 because the immediates take no place in the stream.
+var twoFrames = Data{
+	PLAINARRAY, 3,
+	FRAME, 2, SYMBOL, 4, 'n', 'a', 'm', 'e', SYMBOL, 2, 'i', 'd', STRING, 2, 0, 'a', IMMEDIATE, 1 << 2,
+	FRAME, 2, PRECEDENT, 2, PRECEDENT, 3, STRING, 2, 0, 'b', IMMEDIATE, 2 << 2,
+	PRECEDENT, 6,
+}
+
+func TestDecodePrecedents(t *testing.T) {
+	data := append(Data(nil), twoFrames...)
+	var stream ObjectStream
+	object, err := data.DecodeObject(&stream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = `[{'name: "a", 'id: 1}, {'name: "b", 'id: 2}, "b"]`
+	if got := object.String(); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	array := object.(*PlainArray)
+	second := array.Objects[1].(*Frame)
+	if second.Slots[0].Key != array.Objects[0].(*Frame).Slots[0].Key {
+		t.Errorf("the precedent does not refer to the symbol of the first frame")
+	}
+	if array.Objects[2] != second.Slots[0].Value {
+		t.Errorf("the precedent does not refer to the string of the second frame")
+	}
+	if len(stream) != 7 {
+		t.Errorf("got %d objects in the stream, want 7", len(stream))
+	}
+}
+
+func TestFactory(t *testing.T) {
+	objects, err := twoFrames.Factory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		`[{'name: "a", 'id: 1}, {'name: "b", 'id: 2}, "b"]`,
+		`{'name: "a", 'id: 1}`, `'name`, `'id`, `"a"`,
+		`{'name: "b", 'id: 2}`, `"b"`,
+	}
+	if len(objects) != len(want) {
+		t.Fatalf("got %d objects, want %d", len(objects), len(want))
+	}
+	for i, object := range objects {
+		if object.String() != want[i] {
+			t.Errorf("object %d: got %s, want %s", i, object, want[i])
+		}
+	}
+}
+
+func TestDecodeBadPrecedent(t *testing.T) {
+	tests := []struct {
+		name string
+		data Data
+	}{
+		{"ahead", Data{PLAINARRAY, 1, PRECEDENT, 1}},
+		{"immediate", Data{PLAINARRAY, 2, IMMEDIATE, 1 << 2, PRECEDENT, 1}},
+		{"negative", Data{PRECEDENT, 255, 0xff, 0xff, 0xff, 0xff}},
+	}
+	for _, test := range tests {
+		var stream ObjectStream
+		_, err := test.data.DecodeObject(&stream)
+		var decodeErr *DecodeError
+		if !errors.As(err, &decodeErr) || !errors.Is(err, ErrBadPrecedent) {
+			t.Errorf("%s: got %v, want a DecodeError for %v", test.name, err, ErrBadPrecedent)
+		}
+	}
+}
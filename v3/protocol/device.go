@@ -0,0 +1,54 @@
+package protocol
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DeviceInfo describes a docked Newton, as sent in NEWTON_NAME and
+// NEWTON_INFO. Newtons with older system software leave some fields zero.
+type DeviceInfo struct {
+	// Name is the owner's name.
+	Name                   string
+	NewtonID               uint32
+	Manufacturer           uint32
+	MachineType            uint32
+	ROMVersion             uint32
+	ROMStage               uint32
+	RAMSize                uint32
+	ScreenHeight           uint32
+	ScreenWidth            uint32
+	PatchVersion           uint32
+	OSVersion              uint32
+	InternalStoreSignature uint32
+	ScreenResolutionV      uint32
+	ScreenResolutionH      uint32
+	ScreenDepth            uint32
+	SystemFlags            uint32
+	SerialNumber           uint64
+	TargetProtocol         uint32
+	// ProtocolVersion is the version of the dock protocol the Newton
+	// speaks.
+	ProtocolVersion uint32
+}
+
+// version formats a version number with the major version in the upper
+// 16 bits.
+func version(v uint32) string {
+	return fmt.Sprintf("%d.%d", v>>16, v&0xffff)
+}
+
+func (info DeviceInfo) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Name: %s\n", info.Name)
+	fmt.Fprintf(&b, "Newton ID: %08x\n", info.NewtonID)
+	fmt.Fprintf(&b, "Manufacturer: %08x\n", info.Manufacturer)
+	fmt.Fprintf(&b, "Machine type: %08x\n", info.MachineType)
+	fmt.Fprintf(&b, "ROM version: %s (stage %08x)\n", version(info.ROMVersion), info.ROMStage)
+	fmt.Fprintf(&b, "OS version: %s, patch %d\n", version(info.OSVersion), info.PatchVersion)
+	fmt.Fprintf(&b, "RAM: %d KB\n", info.RAMSize/1024)
+	fmt.Fprintf(&b, "Screen: %dx%d, %d bit\n", info.ScreenWidth, info.ScreenHeight, info.ScreenDepth)
+	fmt.Fprintf(&b, "Serial number: %016x\n", info.SerialNumber)
+	fmt.Fprintf(&b, "Protocol version: %d", info.ProtocolVersion)
+	return b.String()
+}
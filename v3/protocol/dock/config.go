@@ -0,0 +1,112 @@
+package dock
+
+import (
+	"gdcl/v3/nsof"
+	"gdcl/v3/protocol/messages"
+	"time"
+)
+
+// Desktop types
+const (
+	DesktopMac     uint32 = 0
+	DesktopWindows uint32 = 1
+)
+
+// Session types
+const (
+	NoSession      uint32 = 0
+	SettingUp      uint32 = 1
+	Synchronize    uint32 = 3
+	Restore        uint32 = 4
+	LoadPackage    uint32 = 5
+	TestComm       uint32 = 6
+	LoadPatch      uint32 = 7
+	UpdatingStores uint32 = 8
+)
+
+// Icons of the Newton's connection slip
+const (
+	BackupIcon   uint32 = 1
+	RestoreIcon  uint32 = 2
+	InstallIcon  uint32 = 4
+	ImportIcon   uint32 = 8
+	SyncIcon     uint32 = 16
+	KeyboardIcon uint32 = 32
+	AllIcons     uint32 = 63
+)
+
+// DefaultTimeout is the time the Newton waits for the desktop if
+// Config.Timeout is zero.
+const DefaultTimeout = 10 * time.Second
+
+// App is a desktop application announced to the Newton.
+type App struct {
+	ID      int32
+	Name    string
+	Version int32
+}
+
+// ConnectionUtilities is the desktop application announced if Config.Apps
+// is empty.
+var ConnectionUtilities = App{ID: 2, Name: "Newton Connection Utilities", Version: 1}
+
+// Config is the desktop side of the docking handshake.
+type Config struct {
+	// Password is the password set on the Newton, empty for none.
+	Password string
+	// DesktopType is DesktopMac or DesktopWindows.
+	DesktopType uint32
+	// SessionType is the kind of session, SettingUp if zero.
+	SessionType uint32
+	// Icons are the buttons the Newton offers in its connection slip, an
+	// or of the icon constants.
+	Icons uint32
+	// Timeout is how long the Newton waits for the desktop before it
+	// gives up, DefaultTimeout if zero.
+	Timeout time.Duration
+	// KeepAlive is how long the session may be idle before the desktop
+	// sends a HELLO, half of the timeout if zero. A negative KeepAlive
+	// turns the HELLOs off.
+	KeepAlive time.Duration
+	// Apps are the desktop applications, ConnectionUtilities if empty.
+	Apps []App
+}
+
+func (config *Config) sessionType() uint32 {
+	if config.SessionType == NoSession {
+		return SettingUp
+	}
+	return config.SessionType
+}
+
+func (config *Config) timeoutSeconds() uint32 {
+	if config.Timeout <= 0 {
+		return uint32(DefaultTimeout / time.Second)
+	}
+	return uint32((config.Timeout + time.Second - 1) / time.Second)
+}
+
+func (config *Config) keepAlive() time.Duration {
+	if config.KeepAlive == 0 {
+		return time.Duration(config.timeoutSeconds()) * time.Second / 2
+	}
+	return config.KeepAlive
+}
+
+// desktopApps returns the apps as sent in DESKTOP_INFO, an array of frames
+// with the slots id, name and version.
+func (config *Config) desktopApps() nsof.Object {
+	apps := config.Apps
+	if len(apps) == 0 {
+		apps = []App{ConnectionUtilities}
+	}
+	array := &nsof.PlainArray{}
+	for _, app := range apps {
+		array.Objects = append(array.Objects, &nsof.Frame{Slots: []nsof.Slot{
+			{Key: &nsof.Symbol{Value: "id"}, Value: &nsof.Integer{Value: app.ID}},
+			{Key: &nsof.Symbol{Value: "name"}, Value: messages.NewString(app.Name)},
+			{Key: &nsof.Symbol{Value: "version"}, Value: &nsof.Integer{Value: app.Version}},
+		}})
+	}
+	return array
+}
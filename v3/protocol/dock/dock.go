@@ -1,22 +1,12 @@
 package dock
 
 import (
-	"bytes"
-	"crypto/des"
+	"crypto/rand"
 	"encoding/binary"
+	"errors"
 	"gdcl/v3/fsm"
 	"gdcl/v3/protocol"
-)
-
-const (
-	none           = 0
-	settingUp      = 1
-	synchronize    = 3
-	restore        = 4
-	loadPackage    = 5
-	testComm       = 6
-	loadPatch      = 7
-	updatingStores = 8
+	"gdcl/v3/protocol/messages"
 )
 
 const (
@@ -37,116 +27,274 @@ const (
 	sendPassword
 	sendTimeout
 	passwordError
+	resultError
 	connected
+	disconnected
 )
 
-const (
-	desktopMac      byte = 0
-	desktopWin      byte = 1
-	protocolVersion byte = 10
-	dockTimeout     byte = 5
-)
-
-const (
-	backupIcon   byte = 1
-	restoreIcon  byte = 2
-	installIcon  byte = 4
-	importIcon   byte = 8
-	syncIcon     byte = 16
-	keyboardIcon byte = 32
-	allIcons     byte = 63
-)
+// protocolVersion is the version of the dock protocol spoken by gdcl.
+const protocolVersion uint32 = 10
 
 var transitions = []fsm.Transition[int, protocol.Command, int]{
 	{State: idle, Event: protocol.REQUEST_TO_DOCK, Action: initiateDocking, NewState: initiating},
-	{State: idle, Fallback: true, NewState: idle},
 	{State: initiating, Event: protocol.NEWTON_NAME, Action: sendDesktopInfo, NewState: sentDesktopInfo},
-	{State: initiating, Fallback: true, NewState: initiating},
 	{State: sentDesktopInfo, Event: protocol.NEWTON_INFO, Action: sendWhichIcons, NewState: sentWhichIcons},
-	{State: sentDesktopInfo, Fallback: true, NewState: sentDesktopInfo},
+	{State: sentWhichIcons, Event: protocol.RESULT, Guard: messages.ResultFailed, Action: resultError, NewState: idle},
 	{State: sentWhichIcons, Event: protocol.RESULT, Action: sendTimeout, NewState: sentTimeout},
-	{State: sentWhichIcons, Fallback: true, NewState: sentWhichIcons},
 	{State: sentTimeout, Event: protocol.PASSWORD, Action: sendPassword, NewState: sentPassword},
-	{State: sentTimeout, Fallback: true, NewState: sentTimeout},
 	{State: sentPassword, Event: protocol.HELLO, Action: connected, NewState: up},
 	{State: sentPassword, Event: protocol.RESULT, Action: passwordError, NewState: idle},
-	{State: up, Fallback: true, NewState: up},
+	{State: idle, Fallback: true, KeepState: true},
+	{AnyState: true, Event: protocol.DISCONNECT, Action: disconnected, NewState: idle},
+	{AnyState: true, Fallback: true, KeepState: true},
+}
+
+// Table describes the docking handshake.
+var Table = &fsm.Table[int, protocol.Command, int]{
+	Name:        "dock",
+	Transitions: transitions,
+	Initial:     []int{idle},
+	States: map[int]string{
+		idle:            "idle",
+		initiating:      "initiating",
+		sentDesktopInfo: "sentDesktopInfo",
+		sentWhichIcons:  "sentWhichIcons",
+		sentTimeout:     "sentTimeout",
+		sentPassword:    "sentPassword",
+		up:              "up",
+	},
+	Actions: map[int]string{
+		initiateDocking: "initiateDocking",
+		sendDesktopInfo: "sendDesktopInfo",
+		sendWhichIcons:  "sendWhichIcons",
+		sendPassword:    "sendPassword",
+		sendTimeout:     "sendTimeout",
+		passwordError:   "passwordError",
+		resultError:     "resultError",
+		connected:       "connected",
+		disconnected:    "disconnected",
+	},
+}
+
+func init() {
+	Table.MustValidate()
+}
+
+// Layer is the dock layer of one session. It performs the docking
+// handshake and signals the modules once the session is up.
+type Layer struct {
+	events           chan<- protocol.Event
+	machine          fsm.Machine[int, protocol.Command, int]
+	config           Config
+	key              []byte
+	desktopChallenge uint64
+	newtonChallenge  uint64
+	device           *protocol.DeviceInfo
+	keepAliveTimer   *protocol.Timer
+}
+
+func New(events chan<- protocol.Event, config Config) *Layer {
+	return &Layer{
+		events:  events,
+		machine: fsm.Machine[int, protocol.Command, int]{State: idle, Table: Table},
+		config:  config,
+		key:     newtonKey(config.Password),
+	}
 }
 
 var (
-	state           = idle
-	newtonChallenge uint64
+	errPasswordRejected = errors.New("password rejected by the Newton")
+	// errWrongNewton is a Newton which does not know the password, or
+	// which is not a Newton at all.
+	errWrongNewton = errors.New("the Newton did not answer with the same password")
 )
 
-func processIn(event *protocol.DockEvent) {
-	if event.Command == protocol.NEWTON_INFO {
-		buf := bytes.NewBuffer(event.Data[4:])
-		binary.Read(buf, binary.BigEndian, &newtonChallenge)
+// fail ends the session with err. Errors reported by the Newton and
+// errors which are already dock errors are passed on unwrapped.
+func (layer *Layer) fail(err error) {
+	var dockErr *protocol.DockError
+	var resultErr protocol.ResultError
+	if !errors.As(err, &dockErr) && !errors.As(err, &resultErr) {
+		err = &protocol.DockError{Err: err}
+	}
+	layer.events <- &protocol.ErrorEvent{Err: err, Fatal: true}
+}
+
+// send sends message to the Newton.
+func (layer *Layer) send(message messages.Message) {
+	event, err := messages.Event(message)
+	if err != nil {
+		layer.fail(err)
+		return
 	}
+	layer.events <- event
+}
 
-	var action = noAction
-	action, state = fsm.Input(event.Command, state, transitions)
+func (layer *Layer) processIn(event *protocol.DockEvent) {
+	action, err := layer.machine.Input(event.Command, event)
+	if err != nil {
+		layer.events <- &protocol.ErrorEvent{Err: &protocol.DockError{Err: err}}
+		return
+	}
 	switch action {
 	case initiateDocking:
-		protocol.Events <- protocol.NewDockEvent(
-			protocol.INITIATE_DOCKING,
-			protocol.Out,
-			[]byte{0, 0, 0, settingUp},
-		)
+		var challenge [8]byte
+		rand.Read(challenge[:])
+		layer.desktopChallenge = binary.BigEndian.Uint64(challenge[:])
+		layer.send(&messages.InitiateDocking{SessionType: layer.config.sessionType()})
 	case sendTimeout:
-		protocol.Events <- protocol.NewDockEvent(
-			protocol.SET_TIMEOUT,
-			protocol.Out,
-			[]byte{0, 0, 0, 10},
-		)
+		layer.send(&messages.SetTimeout{Seconds: layer.config.timeoutSeconds()})
 	case sendDesktopInfo:
-		protocol.Events <- protocol.NewDockEvent(
-			protocol.DESKTOP_INFO,
-			protocol.Out,
-			[]byte{
-				0, 0, 0, protocolVersion,
-				0, 0, 0, desktopMac,
-				0x64, 0x23, 0xef, 0x02,
-				0xfb, 0xcd, 0xc5, 0xa5,
-				0, 0, 0, settingUp,
-				0, 0, 0, 1,
-				0x02, 0x05, 0x01, 0x06, 0x03, 0x07, 0x02, 0x69, 0x64, 0x07, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x07,
-				0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x00, 0x08, 0x08, 0x38, 0x00, 0x4e, 0x00, 0x65,
-				0x00, 0x77, 0x00, 0x74, 0x00, 0x6f, 0x00, 0x6e, 0x00, 0x20, 0x00, 0x43, 0x00, 0x6f, 0x00, 0x6e,
-				0x00, 0x6e, 0x00, 0x65, 0x00, 0x63, 0x00, 0x74, 0x00, 0x69, 0x00, 0x6f, 0x00, 0x6e, 0x00, 0x20,
-				0x00, 0x55, 0x00, 0x74, 0x00, 0x69, 0x00, 0x6c, 0x00, 0x69, 0x00, 0x74, 0x00, 0x69, 0x00, 0x65,
-				0x00, 0x73, 0x00, 0x00, 0x00, 0x04},
-		)
+		message, err := messages.Decode(event)
+		if err != nil {
+			layer.fail(err)
+			return
+		}
+		layer.device = newDeviceInfo(message.(*messages.NewtonName))
+		layer.send(&messages.DesktopInfo{
+			ProtocolVersion:    protocolVersion,
+			DesktopType:        layer.config.DesktopType,
+			EncryptedKey:       layer.desktopChallenge,
+			SessionType:        layer.config.sessionType(),
+			AllowSelectiveSync: 1,
+			DesktopApps:        layer.config.desktopApps(),
+		})
 	case sendWhichIcons:
-		protocol.Events <- protocol.NewDockEvent(
-			protocol.WHICH_ICONS,
-			protocol.Out,
-			[]byte{0, 0, 0, allIcons},
-		)
+		message, err := messages.Decode(event)
+		if err != nil {
+			layer.fail(err)
+			return
+		}
+		info := message.(*messages.NewtonInfo)
+		layer.newtonChallenge = info.EncryptedKey
+		layer.device.ProtocolVersion = info.ProtocolVersion
+		layer.send(&messages.WhichIcons{Icons: layer.config.Icons})
 	case sendPassword:
-		var buf bytes.Buffer
-		d, _ := des.NewCipher([]byte{0xe4, 0x0f, 0x7e, 0x9f, 0x0a, 0x36, 0x2c, 0xfa})
-		binary.Write(&buf, binary.BigEndian, newtonChallenge)
-		d.Encrypt(buf.Bytes(), buf.Bytes())
-		protocol.Events <- protocol.NewDockEvent(
-			protocol.PASSWORD,
-			protocol.Out,
-			buf.Bytes(),
-		)
+		message, err := messages.Decode(event)
+		if err != nil {
+			layer.fail(err)
+			return
+		}
+		if message.(*messages.Password).Key != encrypt(layer.key, layer.desktopChallenge) {
+			layer.fail(errWrongNewton)
+			return
+		}
+		layer.send(&messages.Password{Key: encrypt(layer.key, layer.newtonChallenge)})
+	case passwordError:
+		if err := messages.ResultErr(event); err != nil {
+			layer.fail(err)
+		} else {
+			layer.fail(errPasswordRejected)
+		}
+	case resultError:
+		layer.fail(messages.ResultErr(event))
 	case connected:
-		protocol.Events <- protocol.NewDockEvent(
-			protocol.APP_CONNECTED,
-			protocol.In,
-			[]byte{},
-		)
+		layer.events <- &protocol.ConnectedEvent{Device: layer.device}
+	case disconnected:
+		// The Newton ended the session; it expects no answer.
+		layer.stopKeepAlive()
 	}
 }
 
-func Process(event protocol.Event) {
+// newDeviceInfo returns the device info from NEWTON_NAME.
+func newDeviceInfo(name *messages.NewtonName) *protocol.DeviceInfo {
+	info := &name.Info
+	return &protocol.DeviceInfo{
+		Name:                   name.Name,
+		NewtonID:               info.NewtonID,
+		Manufacturer:           info.Manufacturer,
+		MachineType:            info.MachineType,
+		ROMVersion:             info.ROMVersion,
+		ROMStage:               info.ROMStage,
+		RAMSize:                info.RAMSize,
+		ScreenHeight:           info.ScreenHeight,
+		ScreenWidth:            info.ScreenWidth,
+		PatchVersion:           info.PatchVersion,
+		OSVersion:              info.NOSVersion,
+		InternalStoreSignature: info.InternalStoreSignature,
+		ScreenResolutionV:      info.ScreenResolutionV,
+		ScreenResolutionH:      info.ScreenResolutionH,
+		ScreenDepth:            info.ScreenDepth,
+		SystemFlags:            info.SystemFlags,
+		SerialNumber:           info.SerialNumber,
+		TargetProtocol:         info.TargetProtocol,
+	}
+}
+
+// restartKeepAlive schedules the next HELLO. The Newton drops the session
+// when it hears nothing from the desktop for the timeout, so while the
+// session is up, a HELLO is sent whenever it has been idle for the
+// keep-alive interval.
+func (layer *Layer) restartKeepAlive() {
+	layer.stopKeepAlive()
+	if layer.machine.State == up && layer.config.keepAlive() > 0 {
+		layer.keepAliveTimer = protocol.StartTimer(layer.events, layer.config.keepAlive())
+	}
+}
+
+func (layer *Layer) stopKeepAlive() {
+	if layer.keepAliveTimer != nil {
+		layer.keepAliveTimer.Stop()
+		layer.keepAliveTimer = nil
+	}
+}
+
+func (layer *Layer) processTimer(event *protocol.TimerEvent) {
+	if event.Timer != layer.keepAliveTimer || layer.machine.State != up {
+		return
+	}
+	layer.send(&messages.Hello{})
+}
+
+// processAttention passes an attention from the Newton on to the modules
+// once the session is up. During docking, the handshake's own timeouts
+// apply.
+func (layer *Layer) processAttention(event *protocol.AttentionEvent) {
+	if event.Direction != protocol.In || layer.machine.State != up {
+		return
+	}
+	layer.events <- &protocol.InterruptEvent{}
+}
+
+func (layer *Layer) disconnect() {
+	layer.stopKeepAlive()
+	if layer.machine.State == idle {
+		return
+	}
+	layer.machine.Goto(idle)
+	layer.send(&messages.Disconnect{})
+}
+
+// Claims takes every command while docking. Once the session is up, the
+// modules handle the commands except for HELLO and DISCONNECT.
+func (layer *Layer) Claims(command protocol.Command) bool {
+	if layer.machine.State != up {
+		return true
+	}
+	return command == protocol.HELLO || command == protocol.DISCONNECT
+}
+
+func (layer *Layer) SetTrace(trace func(step fsm.Step)) {
+	layer.machine.Trace = trace
+}
+
+func (layer *Layer) Process(event protocol.Event) {
 	switch event.(type) {
 	case *protocol.DockEvent:
 		if event.(*protocol.DockEvent).Direction == protocol.In {
-			processIn(event.(*protocol.DockEvent))
+			layer.processIn(event.(*protocol.DockEvent))
 		}
+		layer.restartKeepAlive()
+	case *protocol.ProgressEvent:
+		// A long transfer keeps the session alive as well.
+		layer.restartKeepAlive()
+	case *protocol.TimerEvent:
+		layer.processTimer(event.(*protocol.TimerEvent))
+	case *protocol.AttentionEvent:
+		layer.processAttention(event.(*protocol.AttentionEvent))
+	case *protocol.DisconnectEvent:
+		layer.disconnect()
+	case *protocol.QuitEvent:
+		layer.stopKeepAlive()
 	}
 }
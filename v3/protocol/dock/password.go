@@ -0,0 +1,58 @@
+package dock
+
+import (
+	"crypto/des"
+	"encoding/binary"
+	"math/bits"
+	"unicode/utf16"
+)
+
+// initialKey is the DES key the Newton starts from when it derives the
+// key for a password.
+const initialKey uint64 = 0x57406860626d7464
+
+// newtonKey derives the DES key for password the way the Newton does: the
+// NUL terminated UTF-16 password is encrypted block by block, each block
+// with the result of the previous one as key, and each result is given odd
+// parity in bit 0.
+func newtonKey(password string) []byte {
+	chars := append(utf16.Encode([]rune(password)), 0)
+	for len(chars)%4 != 0 {
+		chars = append(chars, 0)
+	}
+	key := initialKey
+	for i := 0; i < len(chars); i += 4 {
+		block := uint64(chars[i])<<48 | uint64(chars[i+1])<<32 | uint64(chars[i+2])<<16 | uint64(chars[i+3])
+		key = oddParity(encrypt(desKey(key), block))
+	}
+	return desKey(key)
+}
+
+// desKey converts a Newton key to a standard DES key. The Newton's DES
+// takes the key shifted left by one bit, so its key bits are the lower
+// seven bits of each byte, where the standard DES uses the upper seven.
+func desKey(key uint64) []byte {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], key<<1)
+	return b[:]
+}
+
+// oddParity sets bit 0 of each byte of key with an even number of bits set.
+// Like the Newton, it does not clear bit 0 of the other bytes.
+func oddParity(key uint64) uint64 {
+	for shift := 0; shift < 64; shift += 8 {
+		if bits.OnesCount64(key>>shift&0xff)%2 == 0 {
+			key |= 1 << shift
+		}
+	}
+	return key
+}
+
+// encrypt encrypts a challenge with key.
+func encrypt(key []byte, challenge uint64) uint64 {
+	var block [8]byte
+	cipher, _ := des.NewCipher(key)
+	binary.BigEndian.PutUint64(block[:], challenge)
+	cipher.Encrypt(block[:], block[:])
+	return binary.BigEndian.Uint64(block[:])
+}
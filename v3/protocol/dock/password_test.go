@@ -0,0 +1,36 @@
+package dock
+
+import (
+	"bytes"
+	"crypto/des"
+	"encoding/binary"
+	"testing"
+)
+
+// baseKey is the key gdcl used for Newtons without a password before
+// passwords were supported; it was taken from real Newtons.
+var baseKey = []byte{0xe4, 0x0f, 0x7e, 0x9f, 0x0a, 0x36, 0x2c, 0xfa}
+
+func TestNewtonKeyNoPassword(t *testing.T) {
+	if got := newtonKey(""); !bytes.Equal(got, baseKey) {
+		t.Errorf("newtonKey(\"\") = %x, want %x", got, baseKey)
+	}
+}
+
+func TestEncryptNoPassword(t *testing.T) {
+	challenge := uint64(0x0123456789abcdef)
+	// The answer as computed before passwords were supported.
+	var want [8]byte
+	cipher, _ := des.NewCipher(baseKey)
+	binary.BigEndian.PutUint64(want[:], challenge)
+	cipher.Encrypt(want[:], want[:])
+	if got := encrypt(newtonKey(""), challenge); got != binary.BigEndian.Uint64(want[:]) {
+		t.Errorf("encrypt = %016x, want %x", got, want)
+	}
+}
+
+func TestOddParity(t *testing.T) {
+	if got, want := oddParity(0xf207be4f841b167d), uint64(0xf207bf4f851b167d); got != want {
+		t.Errorf("oddParity = %016x, want %016x", got, want)
+	}
+}
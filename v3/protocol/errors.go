@@ -0,0 +1,62 @@
+package protocol
+
+import "errors"
+
+// ErrCanceled ends a session in which the Newton canceled the operation.
+var ErrCanceled = errors.New("operation canceled by the Newton")
+
+// The errors below tell which part of the protocol stack failed. NSOF
+// failures are reported as *nsof.DecodeError.
+
+// TransportError is a failure to open, read from or write to the
+// transport.
+type TransportError struct {
+	Err error
+}
+
+// FramingError is a byte stream which could not be split into frames.
+type FramingError struct {
+	Err error
+}
+
+// MnpError is a malformed or unexpected MNP packet.
+type MnpError struct {
+	Err error
+}
+
+// DockError is a malformed or unexpected dock packet.
+type DockError struct {
+	Err error
+}
+
+func (e *TransportError) Error() string {
+	return "transport: " + e.Err.Error()
+}
+
+func (e *TransportError) Unwrap() error {
+	return e.Err
+}
+
+func (e *FramingError) Error() string {
+	return "framing: " + e.Err.Error()
+}
+
+func (e *FramingError) Unwrap() error {
+	return e.Err
+}
+
+func (e *MnpError) Error() string {
+	return "MNP: " + e.Err.Error()
+}
+
+func (e *MnpError) Unwrap() error {
+	return e.Err
+}
+
+func (e *DockError) Error() string {
+	return "dock: " + e.Err.Error()
+}
+
+func (e *DockError) Unwrap() error {
+	return e.Err
+}
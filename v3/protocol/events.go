@@ -5,6 +5,7 @@ import (
 	"encoding/binary"
 	"encoding/hex"
 	"fmt"
+	"gdcl/v3/fsm"
 )
 
 type Direction byte
@@ -17,25 +18,8 @@ const (
 
 type Event interface{}
 
+// Dock packet header words and dock commands
 const (
-	APP_DATA                     = 0x30303030
-	APP_QUIT                     = 0x30303031
-	APP_DISCONNECT               = 0x30303032
-	APP_GET_DEFAULT_STORE        = 0x30303033
-	APP_GET_STORE_NAMES          = 0x30303034
-	APP_QUERY_SOUP               = 0x30303035
-	APP_CURSOR_FREE              = 0x30303036
-	APP_CURSOR_NEXT              = 0x30303037
-	APP_CURSOR_ENTRY             = 0x30303038
-	APP_SET_CURRENT_STORE        = 0x30303039
-	APP_SET_CURRENT_SOUP         = 0x30303061
-	APP_GET_SOUP_NAMES           = 0x30303032
-	APP_DELETE_ENTRIES           = 0x30303033
-	APP_ADD_ENTRY                = 0x30303034
-	APP_SEND_SOUP                = 0x30303035
-	APP_GET_INFO                 = 0x30303130
-	APP_CONNECTED                = 0x30303131
-	LAST_APP_COMMAND             = 0x32323232
 	NEWT                         = 0x6e657774
 	DOCK                         = 0x646f636b
 	LONGDATA                     = 0x6c647461
@@ -191,6 +175,62 @@ type MnpEvent struct {
 	Data      []byte
 }
 
+// ConnectedEvent is sent by the dock layer once the Newton has accepted the
+// session. Modules start their work when they receive it.
+type ConnectedEvent struct {
+	Device *DeviceInfo
+}
+
+// QuitEvent ends the event loop of a session. It is sent once the link is
+// down, e.g. after an MNP LD or when the transport was closed.
+type QuitEvent struct {
+}
+
+// ProgressEvent reports how much of an outgoing dock packet the Newton
+// has received.
+type ProgressEvent struct {
+	Command Command
+	Done    int
+	Total   int
+}
+
+// FramingErrorEvent is sent when an incoming frame was discarded because its
+// CRC did not match.
+type FramingErrorEvent struct {
+	BadFrames int
+}
+
+// ErrorEvent reports a problem in one of the layers. Unless it is Fatal,
+// the layer handled it by dropping the input that caused it. A fatal error
+// ends the session.
+type ErrorEvent struct {
+	Err   error
+	Fatal bool
+}
+
+// DisconnectEvent asks the protocol layers to end the session cleanly.
+type DisconnectEvent struct {
+}
+
+// CancelEvent asks the modules to cancel the operation in progress, e.g.
+// because the caller's context is done.
+type CancelEvent struct {
+}
+
+// AttentionEvent is an MNP link attention, which reaches the other side
+// ahead of any queued data. A destructive attention discards the data in
+// transit. Modules send one ahead of OPERATION_CANCELED, so that the
+// rest of a long transfer is not sent first.
+type AttentionEvent struct {
+	Direction   Direction
+	Destructive bool
+}
+
+// InterruptEvent is posted by the dock layer when the Newton interrupts
+// the operation in progress with a link attention.
+type InterruptEvent struct {
+}
+
 type DockEvent struct {
 	Direction Direction
 	Data      []byte
@@ -198,7 +238,26 @@ type DockEvent struct {
 	Length    uint32
 }
 
-var Events = make(chan Event, 100)
+// Layer is one part of a session's protocol stack. Every event of the
+// session is passed to every layer.
+type Layer interface {
+	Process(event Event)
+}
+
+// Claimer is implemented by layers which handle incoming dock commands.
+// An incoming command no layer claims is answered by the session, so the
+// Newton does not wait for a reply that never comes.
+type Claimer interface {
+	// Claims reports whether the layer handles command in its current
+	// state. It is called before the command is processed.
+	Claims(command Command) bool
+}
+
+// Tracer is implemented by layers driven by an fsm.Machine, so that the
+// session can trace their transitions.
+type Tracer interface {
+	SetTrace(trace func(step fsm.Step))
+}
 
 func (direction Direction) String() string {
 	if direction == In {
@@ -223,6 +282,51 @@ func (event MnpEvent) String() string {
 	return fmt.Sprintf("MNP (%s):\n%s", event.Direction, hex.Dump(event.Data))
 }
 
+func (event ConnectedEvent) String() string {
+	if event.Device == nil {
+		return "Connected"
+	}
+	return fmt.Sprintf("Connected to %s", event.Device.Name)
+}
+
+func (event QuitEvent) String() string {
+	return "Quit"
+}
+
+func (event ProgressEvent) String() string {
+	return fmt.Sprintf("Progress: %s %d/%d", event.Command, event.Done, event.Total)
+}
+
+func (event FramingErrorEvent) String() string {
+	return fmt.Sprintf("Framing error, %d bad frames", event.BadFrames)
+}
+
+func (event ErrorEvent) String() string {
+	if event.Fatal {
+		return fmt.Sprintf("Fatal error: %s", event.Err)
+	}
+	return fmt.Sprintf("Error: %s", event.Err)
+}
+
+func (event DisconnectEvent) String() string {
+	return "Disconnect"
+}
+
+func (event CancelEvent) String() string {
+	return "Cancel"
+}
+
+func (event AttentionEvent) String() string {
+	if event.Destructive {
+		return fmt.Sprintf("Attention (%s): destructive", event.Direction)
+	}
+	return fmt.Sprintf("Attention (%s)", event.Direction)
+}
+
+func (event InterruptEvent) String() string {
+	return "Interrupt"
+}
+
 func (event DockEvent) String() string {
 	return fmt.Sprintf("Dock (%s): %s %d\n%s",
 		event.Direction, event.Command, event.Length,
@@ -250,11 +354,3 @@ func (event DockEvent) Encode() []byte {
 	buf.Write(event.Data)
 	return buf.Bytes()
 }
-
-func IsQuitEvent(event Event) bool {
-	switch event.(type) {
-	case *DockEvent:
-		return event.(*DockEvent).Command == APP_QUIT
-	}
-	return false
-}
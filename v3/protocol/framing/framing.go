@@ -34,12 +34,22 @@ const (
 	etx byte = 3
 )
 
-var (
-	state         = outsidePacket
+// Layer is the framing of one session. It splits the incoming byte stream
+// into MNP packets and frames outgoing MNP packets.
+type Layer struct {
+	// BadFrames counts the incoming frames discarded because of a CRC
+	// mismatch.
+	BadFrames     int
+	events        chan<- protocol.Event
+	machine       fsm.Machine[int, byte, int]
 	data          []byte
 	receivedCrc   uint16
 	calculatedCrc uint16
-)
+}
+
+func New(events chan<- protocol.Event) *Layer {
+	return &Layer{events: events, machine: fsm.Machine[int, byte, int]{State: outsidePacket, Table: Table}}
+}
 
 var transitions = []fsm.Transition[int, byte, int]{
 	{State: outsidePacket, Event: syn, NewState: startSyn},
@@ -59,35 +69,83 @@ var transitions = []fsm.Transition[int, byte, int]{
 	{State: packetEnd, Fallback: true, NewState: outsidePacket},
 }
 
-func processIn(event *protocol.SerialEvent) {
+// Table is the framing state machine, checked at init and drawn by
+// gdcl fsm-graph.
+var Table = &fsm.Table[int, byte, int]{
+	Name:        "framing",
+	Transitions: transitions,
+	Initial:     []int{outsidePacket},
+	States: map[int]string{
+		outsidePacket: "outsidePacket",
+		startSyn:      "startSyn",
+		startDle:      "startDle",
+		insidePacket:  "insidePacket",
+		dleInPacket:   "dleInPacket",
+		endEtx:        "endEtx",
+		endCrc1:       "endCrc1",
+		packetEnd:     "packetEnd",
+	},
+	Events: map[byte]string{
+		syn: "syn",
+		dle: "dle",
+		stx: "stx",
+		etx: "etx",
+	},
+	Actions: map[int]string{
+		startPacket:         "startPacket",
+		addChar:             "addChar",
+		addDle:              "addDle",
+		updateCalculatedCrc: "updateCalculatedCrc",
+		resetReceivedCrc:    "resetReceivedCrc",
+		packetReceived:      "packetReceived",
+	},
+}
+
+func init() {
+	Table.MustValidate()
+}
+
+func (layer *Layer) processIn(event *protocol.SerialEvent) {
 	var action int
+	var err error
 	for _, input := range event.Data {
-		action, state = fsm.Input(input, state, transitions)
+		action, err = layer.machine.Input(input, nil)
+		if err != nil {
+			layer.events <- &protocol.ErrorEvent{Err: &protocol.FramingError{Err: err}}
+			layer.machine.Goto(outsidePacket)
+			continue
+		}
 		switch action {
 		case startPacket:
-			data = make([]byte, 0, 128)
-			receivedCrc = 0
-			calculatedCrc = 0
+			layer.data = make([]byte, 0, 128)
+			layer.receivedCrc = 0
+			layer.calculatedCrc = 0
 		case addChar:
-			data = append(data, input)
-			calculatedCrc = crc16.Crc16(input, calculatedCrc)
+			layer.data = append(layer.data, input)
+			layer.calculatedCrc = crc16.Crc16(input, layer.calculatedCrc)
 		case addDle:
-			data = append(data, input)
+			layer.data = append(layer.data, input)
+			layer.calculatedCrc = crc16.Crc16(input, layer.calculatedCrc)
 		case updateCalculatedCrc:
-			calculatedCrc = crc16.Crc16(input, calculatedCrc)
+			layer.calculatedCrc = crc16.Crc16(input, layer.calculatedCrc)
 		case resetReceivedCrc:
-			receivedCrc = uint16(input)
+			layer.receivedCrc = uint16(input)
 		case packetReceived:
-			receivedCrc = receivedCrc + uint16(input)<<8
-			protocol.Events <- &protocol.MnpEvent{
+			layer.receivedCrc = layer.receivedCrc + uint16(input)<<8
+			if layer.receivedCrc != layer.calculatedCrc {
+				layer.BadFrames++
+				layer.events <- &protocol.FramingErrorEvent{BadFrames: layer.BadFrames}
+				continue
+			}
+			layer.events <- &protocol.MnpEvent{
 				Direction: protocol.In,
-				Data:      data,
+				Data:      layer.data,
 			}
 		}
 	}
 }
 
-func processOut(event *protocol.MnpEvent) {
+func (layer *Layer) processOut(event *protocol.MnpEvent) {
 	outBuf := make([]byte, 0, len(event.Data)*2+7)
 	crc := uint16(0)
 	outBuf = append(outBuf, syn, dle, stx)
@@ -101,21 +159,25 @@ func processOut(event *protocol.MnpEvent) {
 	outBuf = append(outBuf, dle, etx)
 	crc = crc16.Crc16(etx, crc)
 	outBuf = append(outBuf, byte(crc&0xff), byte(crc>>8))
-	protocol.Events <- &protocol.SerialEvent{
+	layer.events <- &protocol.SerialEvent{
 		Direction: protocol.Out,
 		Data:      outBuf,
 	}
 }
 
-func Process(event protocol.Event) {
+func (layer *Layer) SetTrace(trace func(step fsm.Step)) {
+	layer.machine.Trace = trace
+}
+
+func (layer *Layer) Process(event protocol.Event) {
 	switch event.(type) {
 	case *protocol.SerialEvent:
 		if event.(*protocol.SerialEvent).Direction == protocol.In {
-			processIn(event.(*protocol.SerialEvent))
+			layer.processIn(event.(*protocol.SerialEvent))
 		}
 	case *protocol.MnpEvent:
 		if event.(*protocol.MnpEvent).Direction == protocol.Out {
-			processOut(event.(*protocol.MnpEvent))
+			layer.processOut(event.(*protocol.MnpEvent))
 		}
 	}
 }
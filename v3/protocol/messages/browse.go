@@ -0,0 +1,402 @@
+package messages
+
+import (
+	"gdcl/v3/nsof"
+	"gdcl/v3/protocol"
+)
+
+func init() {
+	register(func() Message { return &RequestToBrowse{} })
+	register(func() Message { return &GetDevices{} })
+	register(func() Message { return &Devices{} })
+	register(func() Message { return &SetDrive{} })
+	register(func() Message { return &GetDefaultPath{} })
+	register(func() Message { return &Path{} })
+	register(func() Message { return &SetPath{} })
+	register(func() Message { return &GetFilesAndFolders{} })
+	register(func() Message { return &FilesAndFolders{} })
+	register(func() Message { return &GetFileInfo{} })
+	register(func() Message { return &FileInfo{} })
+	register(func() Message { return &ResolveAlias{} })
+	register(func() Message { return &AliasResolved{} })
+	register(func() Message { return &GetFilters{} })
+	register(func() Message { return &Filters{} })
+	register(func() Message { return &SetFilter{} })
+	register(func() Message { return &ImportFile{} })
+	register(func() Message { return &TranslatorList{} })
+	register(func() Message { return &SetTranslator{} })
+	register(func() Message { return &Importing{} })
+	register(func() Message { return &ImportParametersSlip{} })
+	register(func() Message { return &ImportParameterSlipResult{} })
+	register(func() Message { return &LoadPackageFile{} })
+}
+
+// RequestToBrowse starts a browsing session. FileTypes is 'import,
+// 'packages, 'syncFiles or an array of file types.
+type RequestToBrowse struct {
+	FileTypes nsof.Object
+	Extra     []byte
+}
+
+func (*RequestToBrowse) Command() protocol.Command {
+	return protocol.REQUEST_TO_BROWSE
+}
+
+func (m *RequestToBrowse) Marshal() ([]byte, error) {
+	return marshalObject(m.FileTypes, m.Extra)
+}
+
+func (m *RequestToBrowse) Unmarshal(data []byte) error {
+	return unmarshalObject(data, &m.FileTypes, &m.Extra)
+}
+
+// GetDevices asks for the drives of the desktop.
+type GetDevices struct{ empty }
+
+func (*GetDevices) Command() protocol.Command {
+	return protocol.GET_DEVICES
+}
+
+// Devices is the answer to GetDevices, an array of frames with the name
+// and kind of each drive.
+type Devices struct {
+	Devices nsof.Object
+	Extra   []byte
+}
+
+func (*Devices) Command() protocol.Command {
+	return protocol.DEVICES
+}
+
+func (m *Devices) Marshal() ([]byte, error) {
+	return marshalObject(m.Devices, m.Extra)
+}
+
+func (m *Devices) Unmarshal(data []byte) error {
+	return unmarshalObject(data, &m.Devices, &m.Extra)
+}
+
+// SetDrive selects a drive by name.
+type SetDrive struct {
+	Drive string
+	Extra []byte
+}
+
+func (*SetDrive) Command() protocol.Command {
+	return protocol.SET_DRIVE
+}
+
+func (m *SetDrive) Marshal() ([]byte, error) {
+	return marshalString(m.Drive, m.Extra)
+}
+
+func (m *SetDrive) Unmarshal(data []byte) error {
+	return unmarshalString(data, &m.Drive, &m.Extra)
+}
+
+// GetDefaultPath asks for the folder to start browsing in.
+type GetDefaultPath struct{ empty }
+
+func (*GetDefaultPath) Command() protocol.Command {
+	return protocol.GET_DEFAULT_PATH
+}
+
+// Path is the answer to GetDefaultPath and SetPath, an array of frames,
+// one per folder down from the desktop.
+type Path struct {
+	Path  nsof.Object
+	Extra []byte
+}
+
+func (*Path) Command() protocol.Command {
+	return protocol.PATH
+}
+
+func (m *Path) Marshal() ([]byte, error) {
+	return marshalObject(m.Path, m.Extra)
+}
+
+func (m *Path) Unmarshal(data []byte) error {
+	return unmarshalObject(data, &m.Path, &m.Extra)
+}
+
+// SetPath changes to the folder at Path, an array of folder names.
+type SetPath struct {
+	Path  nsof.Object
+	Extra []byte
+}
+
+func (*SetPath) Command() protocol.Command {
+	return protocol.SET_PATH
+}
+
+func (m *SetPath) Marshal() ([]byte, error) {
+	return marshalObject(m.Path, m.Extra)
+}
+
+func (m *SetPath) Unmarshal(data []byte) error {
+	return unmarshalObject(data, &m.Path, &m.Extra)
+}
+
+// GetFilesAndFolders asks for the contents of the current folder.
+type GetFilesAndFolders struct{ empty }
+
+func (*GetFilesAndFolders) Command() protocol.Command {
+	return protocol.GET_FILES_AND_FOLDERS
+}
+
+// FilesAndFolders is the answer to GetFilesAndFolders, an array of frames
+// with the name and type of each item.
+type FilesAndFolders struct {
+	Items nsof.Object
+	Extra []byte
+}
+
+func (*FilesAndFolders) Command() protocol.Command {
+	return protocol.FILES_AND_FOLDERS
+}
+
+func (m *FilesAndFolders) Marshal() ([]byte, error) {
+	return marshalObject(m.Items, m.Extra)
+}
+
+func (m *FilesAndFolders) Unmarshal(data []byte) error {
+	return unmarshalObject(data, &m.Items, &m.Extra)
+}
+
+// GetFileInfo asks for the details of a file in the current folder.
+type GetFileInfo struct {
+	Name  string
+	Extra []byte
+}
+
+func (*GetFileInfo) Command() protocol.Command {
+	return protocol.GET_FILE_INFO
+}
+
+func (m *GetFileInfo) Marshal() ([]byte, error) {
+	return marshalString(m.Name, m.Extra)
+}
+
+func (m *GetFileInfo) Unmarshal(data []byte) error {
+	return unmarshalString(data, &m.Name, &m.Extra)
+}
+
+// FileInfo is the answer to GetFileInfo, a frame with the kind, size,
+// dates and so on.
+type FileInfo struct {
+	Info  nsof.Object
+	Extra []byte
+}
+
+func (*FileInfo) Command() protocol.Command {
+	return protocol.FILE_INFO
+}
+
+func (m *FileInfo) Marshal() ([]byte, error) {
+	return marshalObject(m.Info, m.Extra)
+}
+
+func (m *FileInfo) Unmarshal(data []byte) error {
+	return unmarshalObject(data, &m.Info, &m.Extra)
+}
+
+// ResolveAlias asks whether an alias in the current folder can be
+// followed.
+type ResolveAlias struct {
+	Alias nsof.Object
+	Extra []byte
+}
+
+func (*ResolveAlias) Command() protocol.Command {
+	return protocol.RESOLVE_ALIAS
+}
+
+func (m *ResolveAlias) Marshal() ([]byte, error) {
+	return marshalObject(m.Alias, m.Extra)
+}
+
+func (m *ResolveAlias) Unmarshal(data []byte) error {
+	return unmarshalObject(data, &m.Alias, &m.Extra)
+}
+
+// AliasResolved is the answer to ResolveAlias, 1 if the alias can be
+// followed.
+type AliasResolved struct {
+	Resolved uint32
+	Extra    []byte
+}
+
+func (*AliasResolved) Command() protocol.Command {
+	return protocol.ALIAS_RESOLVED
+}
+
+func (m *AliasResolved) Marshal() ([]byte, error) {
+	return marshalLong(m.Resolved, m.Extra)
+}
+
+func (m *AliasResolved) Unmarshal(data []byte) error {
+	return unmarshalLong(data, &m.Resolved, &m.Extra)
+}
+
+// GetFilters asks for the file type filters the desktop offers.
+type GetFilters struct{ empty }
+
+func (*GetFilters) Command() protocol.Command {
+	return protocol.GET_FILTERS
+}
+
+// Filters is the answer to GetFilters, an array of filter names.
+type Filters struct {
+	Filters nsof.Object
+	Extra   []byte
+}
+
+func (*Filters) Command() protocol.Command {
+	return protocol.FILTERS
+}
+
+func (m *Filters) Marshal() ([]byte, error) {
+	return marshalObject(m.Filters, m.Extra)
+}
+
+func (m *Filters) Unmarshal(data []byte) error {
+	return unmarshalObject(data, &m.Filters, &m.Extra)
+}
+
+// SetFilter selects a filter by its index in Filters.
+type SetFilter struct {
+	Index uint32
+	Extra []byte
+}
+
+func (*SetFilter) Command() protocol.Command {
+	return protocol.SET_FILTER
+}
+
+func (m *SetFilter) Marshal() ([]byte, error) {
+	return marshalLong(m.Index, m.Extra)
+}
+
+func (m *SetFilter) Unmarshal(data []byte) error {
+	return unmarshalLong(data, &m.Index, &m.Extra)
+}
+
+// ImportFile asks the desktop to import a file of the current folder.
+type ImportFile struct {
+	Name  string
+	Extra []byte
+}
+
+func (*ImportFile) Command() protocol.Command {
+	return protocol.IMPORT_FILE
+}
+
+func (m *ImportFile) Marshal() ([]byte, error) {
+	return marshalString(m.Name, m.Extra)
+}
+
+func (m *ImportFile) Unmarshal(data []byte) error {
+	return unmarshalString(data, &m.Name, &m.Extra)
+}
+
+// TranslatorList offers the translators which can import a file, for the
+// user to pick one.
+type TranslatorList struct {
+	Translators nsof.Object
+	Extra       []byte
+}
+
+func (*TranslatorList) Command() protocol.Command {
+	return protocol.TRANSLATOR_LIST
+}
+
+func (m *TranslatorList) Marshal() ([]byte, error) {
+	return marshalObject(m.Translators, m.Extra)
+}
+
+func (m *TranslatorList) Unmarshal(data []byte) error {
+	return unmarshalObject(data, &m.Translators, &m.Extra)
+}
+
+// SetTranslator selects a translator by its index in TranslatorList.
+type SetTranslator struct {
+	Index uint32
+	Extra []byte
+}
+
+func (*SetTranslator) Command() protocol.Command {
+	return protocol.SET_TRANSLATOR
+}
+
+func (m *SetTranslator) Marshal() ([]byte, error) {
+	return marshalLong(m.Index, m.Extra)
+}
+
+func (m *SetTranslator) Unmarshal(data []byte) error {
+	return unmarshalLong(data, &m.Index, &m.Extra)
+}
+
+// Importing tells the Newton that the desktop is importing a file.
+type Importing struct{ empty }
+
+func (*Importing) Command() protocol.Command {
+	return protocol.IMPORTING
+}
+
+// ImportParametersSlip asks the Newton to show a slip for the import
+// options of a translator.
+type ImportParametersSlip struct {
+	Slip  nsof.Object
+	Extra []byte
+}
+
+func (*ImportParametersSlip) Command() protocol.Command {
+	return protocol.IMPORT_PARAMETERS_SLIP
+}
+
+func (m *ImportParametersSlip) Marshal() ([]byte, error) {
+	return marshalObject(m.Slip, m.Extra)
+}
+
+func (m *ImportParametersSlip) Unmarshal(data []byte) error {
+	return unmarshalObject(data, &m.Slip, &m.Extra)
+}
+
+// ImportParameterSlipResult is the answer to ImportParametersSlip, the
+// options the user picked.
+type ImportParameterSlipResult struct {
+	Result nsof.Object
+	Extra  []byte
+}
+
+func (*ImportParameterSlipResult) Command() protocol.Command {
+	return protocol.IMPORT_PARAMETER_SLIP_RESULT
+}
+
+func (m *ImportParameterSlipResult) Marshal() ([]byte, error) {
+	return marshalObject(m.Result, m.Extra)
+}
+
+func (m *ImportParameterSlipResult) Unmarshal(data []byte) error {
+	return unmarshalObject(data, &m.Result, &m.Extra)
+}
+
+// LoadPackageFile asks the desktop to install a package file of the
+// current folder.
+type LoadPackageFile struct {
+	Name  string
+	Extra []byte
+}
+
+func (*LoadPackageFile) Command() protocol.Command {
+	return protocol.LOAD_PACKAGE_FILE
+}
+
+func (m *LoadPackageFile) Marshal() ([]byte, error) {
+	return marshalString(m.Name, m.Extra)
+}
+
+func (m *LoadPackageFile) Unmarshal(data []byte) error {
+	return unmarshalString(data, &m.Name, &m.Extra)
+}
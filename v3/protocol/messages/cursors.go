@@ -0,0 +1,245 @@
+package messages
+
+import (
+	"gdcl/v3/nsof"
+	"gdcl/v3/protocol"
+)
+
+func init() {
+	register(func() Message { return &Query{} })
+	register(func() Message { return &LongData{} })
+	register(func() Message { return &RefResult{} })
+	register(func() Message { return &CursorGotoKey{} })
+	register(func() Message { return &CursorMap{} })
+	register(func() Message { return &CursorEntry{} })
+	register(func() Message { return &CursorMove{} })
+	register(func() Message { return &CursorNext{} })
+	register(func() Message { return &CursorPrev{} })
+	register(func() Message { return &CursorReset{} })
+	register(func() Message { return &CursorResetToEnd{} })
+	register(func() Message { return &CursorCountEntries{} })
+	register(func() Message { return &CursorWhichEnd{} })
+	register(func() Message { return &CursorFree{} })
+}
+
+// Query opens a cursor on a soup. The Newton answers with the cursor ID
+// in a LongData.
+type Query struct {
+	Soup  string
+	Spec  nsof.Object
+	Extra []byte
+}
+
+func (*Query) Command() protocol.Command {
+	return protocol.QUERY
+}
+
+func (m *Query) Marshal() ([]byte, error) {
+	if m.Spec == nil {
+		return nil, errMissingObject
+	}
+	var w writer
+	w.object(NewString(m.Soup))
+	w.object(m.Spec)
+	return append(w.data, m.Extra...), nil
+}
+
+func (m *Query) Unmarshal(data []byte) error {
+	r := reader{data: data}
+	m.Soup = StringValue(r.object())
+	m.Spec = r.object()
+	m.Extra = r.rest()
+	return r.err
+}
+
+// LongData is a number returned by a command, such as a cursor ID or an
+// entry count.
+type LongData struct {
+	Value uint32
+	Extra []byte
+}
+
+func (*LongData) Command() protocol.Command {
+	return protocol.LONGDATA
+}
+
+func (m *LongData) Marshal() ([]byte, error) {
+	return marshalLong(m.Value, m.Extra)
+}
+
+func (m *LongData) Unmarshal(data []byte) error {
+	return unmarshalLong(data, &m.Value, &m.Extra)
+}
+
+// RefResult is an NSOF object returned by a command.
+type RefResult struct {
+	Ref   nsof.Object
+	Extra []byte
+}
+
+func (*RefResult) Command() protocol.Command {
+	return protocol.REF_RESULT
+}
+
+func (m *RefResult) Marshal() ([]byte, error) {
+	return marshalObject(m.Ref, m.Extra)
+}
+
+func (m *RefResult) Unmarshal(data []byte) error {
+	return unmarshalObject(data, &m.Ref, &m.Extra)
+}
+
+// cursor is embedded by the cursor commands which only carry the cursor ID.
+type cursor struct {
+	Cursor uint32
+	Extra  []byte
+}
+
+func (m *cursor) Marshal() ([]byte, error) {
+	return marshalLong(m.Cursor, m.Extra)
+}
+
+func (m *cursor) Unmarshal(data []byte) error {
+	return unmarshalLong(data, &m.Cursor, &m.Extra)
+}
+
+// CursorGotoKey moves a cursor to the first entry with the given key.
+type CursorGotoKey struct {
+	Cursor uint32
+	Key    nsof.Object
+	Extra  []byte
+}
+
+func (*CursorGotoKey) Command() protocol.Command {
+	return protocol.CURSOR_GOTO_KEY
+}
+
+func (m *CursorGotoKey) Marshal() ([]byte, error) {
+	if m.Key == nil {
+		return nil, errMissingObject
+	}
+	var w writer
+	w.long(m.Cursor)
+	w.object(m.Key)
+	return append(w.data, m.Extra...), nil
+}
+
+func (m *CursorGotoKey) Unmarshal(data []byte) error {
+	r := reader{data: data}
+	m.Cursor = r.long()
+	m.Key = r.object()
+	m.Extra = r.rest()
+	return r.err
+}
+
+// CursorMap calls a function for every entry of a cursor.
+type CursorMap struct {
+	Cursor   uint32
+	Function nsof.Object
+	Extra    []byte
+}
+
+func (*CursorMap) Command() protocol.Command {
+	return protocol.CURSOR_MAP
+}
+
+func (m *CursorMap) Marshal() ([]byte, error) {
+	if m.Function == nil {
+		return nil, errMissingObject
+	}
+	var w writer
+	w.long(m.Cursor)
+	w.object(m.Function)
+	return append(w.data, m.Extra...), nil
+}
+
+func (m *CursorMap) Unmarshal(data []byte) error {
+	r := reader{data: data}
+	m.Cursor = r.long()
+	m.Function = r.object()
+	m.Extra = r.rest()
+	return r.err
+}
+
+// CursorEntry asks for the entry at the position of a cursor.
+type CursorEntry struct{ cursor }
+
+func (*CursorEntry) Command() protocol.Command {
+	return protocol.CURSOR_ENTRY
+}
+
+// CursorMove moves a cursor by Offset entries.
+type CursorMove struct {
+	Cursor uint32
+	Offset int32
+	Extra  []byte
+}
+
+func (*CursorMove) Command() protocol.Command {
+	return protocol.CURSOR_MOVE
+}
+
+func (m *CursorMove) Marshal() ([]byte, error) {
+	var w writer
+	w.long(m.Cursor)
+	w.long(uint32(m.Offset))
+	return append(w.data, m.Extra...), nil
+}
+
+func (m *CursorMove) Unmarshal(data []byte) error {
+	r := reader{data: data}
+	m.Cursor = r.long()
+	m.Offset = int32(r.long())
+	m.Extra = r.rest()
+	return r.err
+}
+
+// CursorNext moves a cursor to the next entry.
+type CursorNext struct{ cursor }
+
+func (*CursorNext) Command() protocol.Command {
+	return protocol.CURSOR_NEXT
+}
+
+// CursorPrev moves a cursor to the previous entry.
+type CursorPrev struct{ cursor }
+
+func (*CursorPrev) Command() protocol.Command {
+	return protocol.CURSOR_PREV
+}
+
+// CursorReset moves a cursor to the first entry.
+type CursorReset struct{ cursor }
+
+func (*CursorReset) Command() protocol.Command {
+	return protocol.CURSOR_RESET
+}
+
+// CursorResetToEnd moves a cursor to the last entry.
+type CursorResetToEnd struct{ cursor }
+
+func (*CursorResetToEnd) Command() protocol.Command {
+	return protocol.CURSOR_RESET_TO_END
+}
+
+// CursorCountEntries asks for the number of entries of a cursor.
+type CursorCountEntries struct{ cursor }
+
+func (*CursorCountEntries) Command() protocol.Command {
+	return protocol.CURSOR_COUNT_ENTRIES
+}
+
+// CursorWhichEnd asks whether a cursor is before the first or after the
+// last entry.
+type CursorWhichEnd struct{ cursor }
+
+func (*CursorWhichEnd) Command() protocol.Command {
+	return protocol.CURSOR_WHICH_END
+}
+
+// CursorFree disposes of a cursor.
+type CursorFree struct{ cursor }
+
+func (*CursorFree) Command() protocol.Command {
+	return protocol.CURSOR_FREE
+}
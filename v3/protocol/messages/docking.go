@@ -0,0 +1,398 @@
+package messages
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"gdcl/v3/nsof"
+	"gdcl/v3/protocol"
+)
+
+func init() {
+	register(func() Message { return &RequestToDock{} })
+	register(func() Message { return &InitiateDocking{} })
+	register(func() Message { return &NewtonName{} })
+	register(func() Message { return &DesktopInfo{} })
+	register(func() Message { return &NewtonInfo{} })
+	register(func() Message { return &WhichIcons{} })
+	register(func() Message { return &SetTimeout{} })
+	register(func() Message { return &Password{} })
+	register(func() Message { return &Result{} })
+	register(func() Message { return &ResultString{} })
+	register(func() Message { return &Hello{} })
+	register(func() Message { return &Disconnect{} })
+	register(func() Message { return &OperationDone{} })
+	register(func() Message { return &OperationCanceled{} })
+	register(func() Message { return &OpCanceledAck{} })
+	register(func() Message { return &UnknownCommand{} })
+}
+
+// RequestToDock is sent by the Newton to start a session.
+type RequestToDock struct {
+	ProtocolVersion uint32
+	Extra           []byte
+}
+
+func (*RequestToDock) Command() protocol.Command {
+	return protocol.REQUEST_TO_DOCK
+}
+
+func (m *RequestToDock) Marshal() ([]byte, error) {
+	return marshalLong(m.ProtocolVersion, m.Extra)
+}
+
+func (m *RequestToDock) Unmarshal(data []byte) error {
+	return unmarshalLong(data, &m.ProtocolVersion, &m.Extra)
+}
+
+// InitiateDocking answers RequestToDock with the kind of session the
+// desktop wants.
+type InitiateDocking struct {
+	SessionType uint32
+	Extra       []byte
+}
+
+func (*InitiateDocking) Command() protocol.Command {
+	return protocol.INITIATE_DOCKING
+}
+
+func (m *InitiateDocking) Marshal() ([]byte, error) {
+	return marshalLong(m.SessionType, m.Extra)
+}
+
+func (m *InitiateDocking) Unmarshal(data []byte) error {
+	return unmarshalLong(data, &m.SessionType, &m.Extra)
+}
+
+// VersionInfo describes the Newton. Newtons with older system software
+// send fewer fields, the missing ones are zero.
+type VersionInfo struct {
+	NewtonID               uint32
+	Manufacturer           uint32
+	MachineType            uint32
+	ROMVersion             uint32
+	ROMStage               uint32
+	RAMSize                uint32
+	ScreenHeight           uint32
+	ScreenWidth            uint32
+	PatchVersion           uint32
+	NOSVersion             uint32
+	InternalStoreSignature uint32
+	ScreenResolutionV      uint32
+	ScreenResolutionH      uint32
+	ScreenDepth            uint32
+	SystemFlags            uint32
+	SerialNumber           uint64
+	TargetProtocol         uint32
+}
+
+// NewtonName is the Newton's answer to InitiateDocking.
+type NewtonName struct {
+	Info VersionInfo
+	Name string
+	// Extra is the part of the version info beyond the fields of
+	// VersionInfo.
+	Extra []byte
+}
+
+func (*NewtonName) Command() protocol.Command {
+	return protocol.NEWTON_NAME
+}
+
+func (m *NewtonName) Marshal() ([]byte, error) {
+	var info bytes.Buffer
+	binary.Write(&info, binary.BigEndian, &m.Info)
+	info.Write(m.Extra)
+	var w writer
+	w.long(uint32(info.Len()))
+	w.data = append(w.data, info.Bytes()...)
+	return writeUnicode(w.data, m.Name), nil
+}
+
+func (m *NewtonName) Unmarshal(data []byte) error {
+	r := reader{data: data}
+	info := r.bytes(int(r.long()))
+	if r.err != nil {
+		return r.err
+	}
+	size := binary.Size(&m.Info)
+	padded := append(append([]byte{}, info...), make([]byte, max(size-len(info), 0))...)
+	binary.Read(bytes.NewReader(padded), binary.BigEndian, &m.Info)
+	if len(info) > size {
+		m.Extra = info[size:]
+	}
+	m.Name = readUnicode(r.rest())
+	return nil
+}
+
+// DesktopInfo describes the desktop to the Newton.
+type DesktopInfo struct {
+	ProtocolVersion    uint32
+	DesktopType        uint32
+	EncryptedKey       uint64
+	SessionType        uint32
+	AllowSelectiveSync uint32
+	// DesktopApps is an array of frames with the id, name and version of
+	// the desktop applications.
+	DesktopApps nsof.Object
+	Extra       []byte
+}
+
+func (*DesktopInfo) Command() protocol.Command {
+	return protocol.DESKTOP_INFO
+}
+
+func (m *DesktopInfo) Marshal() ([]byte, error) {
+	if m.DesktopApps == nil {
+		return nil, errors.New("missing desktop apps")
+	}
+	var w writer
+	w.long(m.ProtocolVersion)
+	w.long(m.DesktopType)
+	w.doubleLong(m.EncryptedKey)
+	w.long(m.SessionType)
+	w.long(m.AllowSelectiveSync)
+	w.object(m.DesktopApps)
+	return append(w.data, m.Extra...), nil
+}
+
+func (m *DesktopInfo) Unmarshal(data []byte) error {
+	r := reader{data: data}
+	m.ProtocolVersion = r.long()
+	m.DesktopType = r.long()
+	m.EncryptedKey = r.doubleLong()
+	m.SessionType = r.long()
+	m.AllowSelectiveSync = r.long()
+	m.DesktopApps = r.object()
+	m.Extra = r.rest()
+	return r.err
+}
+
+// NewtonInfo is the Newton's answer to DesktopInfo. EncryptedKey is the
+// challenge the desktop has to encrypt with the password.
+type NewtonInfo struct {
+	ProtocolVersion uint32
+	EncryptedKey    uint64
+	Extra           []byte
+}
+
+func (*NewtonInfo) Command() protocol.Command {
+	return protocol.NEWTON_INFO
+}
+
+func (m *NewtonInfo) Marshal() ([]byte, error) {
+	var w writer
+	w.long(m.ProtocolVersion)
+	w.doubleLong(m.EncryptedKey)
+	return append(w.data, m.Extra...), nil
+}
+
+func (m *NewtonInfo) Unmarshal(data []byte) error {
+	r := reader{data: data}
+	m.ProtocolVersion = r.long()
+	m.EncryptedKey = r.doubleLong()
+	m.Extra = r.rest()
+	return r.err
+}
+
+// WhichIcons selects the icons the Newton shows in its connection slip.
+type WhichIcons struct {
+	Icons uint32
+	Extra []byte
+}
+
+func (*WhichIcons) Command() protocol.Command {
+	return protocol.WHICH_ICONS
+}
+
+func (m *WhichIcons) Marshal() ([]byte, error) {
+	return marshalLong(m.Icons, m.Extra)
+}
+
+func (m *WhichIcons) Unmarshal(data []byte) error {
+	return unmarshalLong(data, &m.Icons, &m.Extra)
+}
+
+// SetTimeout sets the number of seconds the Newton waits for the desktop.
+type SetTimeout struct {
+	Seconds uint32
+	Extra   []byte
+}
+
+func (*SetTimeout) Command() protocol.Command {
+	return protocol.SET_TIMEOUT
+}
+
+func (m *SetTimeout) Marshal() ([]byte, error) {
+	return marshalLong(m.Seconds, m.Extra)
+}
+
+func (m *SetTimeout) Unmarshal(data []byte) error {
+	return unmarshalLong(data, &m.Seconds, &m.Extra)
+}
+
+// Password carries a challenge encrypted with the password. It is sent in
+// both directions.
+type Password struct {
+	Key   uint64
+	Extra []byte
+}
+
+func (*Password) Command() protocol.Command {
+	return protocol.PASSWORD
+}
+
+func (m *Password) Marshal() ([]byte, error) {
+	var w writer
+	w.doubleLong(m.Key)
+	return append(w.data, m.Extra...), nil
+}
+
+func (m *Password) Unmarshal(data []byte) error {
+	r := reader{data: data}
+	m.Key = r.doubleLong()
+	m.Extra = r.rest()
+	return r.err
+}
+
+// Result is the outcome of a command, 0 for success.
+type Result struct {
+	Code  int32
+	Extra []byte
+}
+
+func (*Result) Command() protocol.Command {
+	return protocol.RESULT
+}
+
+func (m *Result) Marshal() ([]byte, error) {
+	return marshalLong(uint32(m.Code), m.Extra)
+}
+
+func (m *Result) Unmarshal(data []byte) error {
+	var code uint32
+	err := unmarshalLong(data, &code, &m.Extra)
+	m.Code = int32(code)
+	return err
+}
+
+// Err returns Code as a protocol.ResultError, or nil for success.
+func (m *Result) Err() error {
+	if m.Code == 0 {
+		return nil
+	}
+	return protocol.ResultError(m.Code)
+}
+
+// ResultFailed is an fsm guard which matches a RESULT event carrying an
+// error, or one which cannot be decoded.
+func ResultFailed(payload any) bool {
+	event, ok := payload.(*protocol.DockEvent)
+	return ok && ResultErr(event) != nil
+}
+
+// ResultErr decodes a RESULT event and returns the error it carries. A
+// malformed payload is returned as a *protocol.DockError.
+func ResultErr(event *protocol.DockEvent) error {
+	message, err := Decode(event)
+	if err != nil {
+		return &protocol.DockError{Err: err}
+	}
+	result, ok := message.(*Result)
+	if !ok {
+		return &protocol.DockError{Err: fmt.Errorf("expected a result, got %s", event.Command)}
+	}
+	return result.Err()
+}
+
+// ResultString is a Result with a message for the user.
+type ResultString struct {
+	Code    int32
+	Message string
+	Extra   []byte
+}
+
+func (*ResultString) Command() protocol.Command {
+	return protocol.RESULT_STRING
+}
+
+func (m *ResultString) Marshal() ([]byte, error) {
+	var w writer
+	w.long(uint32(m.Code))
+	w.object(NewString(m.Message))
+	return append(w.data, m.Extra...), nil
+}
+
+func (m *ResultString) Unmarshal(data []byte) error {
+	r := reader{data: data}
+	m.Code = int32(r.long())
+	m.Message = StringValue(r.object())
+	m.Extra = r.rest()
+	return r.err
+}
+
+// Err returns Code as a protocol.ResultError, or nil for success.
+func (m *ResultString) Err() error {
+	if m.Code == 0 {
+		return nil
+	}
+	return protocol.ResultError(m.Code)
+}
+
+// UnknownCommand is the answer to a command the other side does not
+// support.
+type UnknownCommand struct {
+	BadCommand protocol.Command
+	Extra      []byte
+}
+
+func (*UnknownCommand) Command() protocol.Command {
+	return protocol.UNKNOWN_COMMAND
+}
+
+func (m *UnknownCommand) Marshal() ([]byte, error) {
+	return marshalLong(uint32(m.BadCommand), m.Extra)
+}
+
+func (m *UnknownCommand) Unmarshal(data []byte) error {
+	var command uint32
+	err := unmarshalLong(data, &command, &m.Extra)
+	m.BadCommand = protocol.Command(command)
+	return err
+}
+
+// Hello keeps the session alive.
+type Hello struct{ empty }
+
+func (*Hello) Command() protocol.Command {
+	return protocol.HELLO
+}
+
+// Disconnect ends the session.
+type Disconnect struct{ empty }
+
+func (*Disconnect) Command() protocol.Command {
+	return protocol.DISCONNECT
+}
+
+// OperationDone ends an operation.
+type OperationDone struct{ empty }
+
+func (*OperationDone) Command() protocol.Command {
+	return protocol.OPERATION_DONE
+}
+
+// OperationCanceled cancels the operation in progress.
+type OperationCanceled struct{ empty }
+
+func (*OperationCanceled) Command() protocol.Command {
+	return protocol.OPERATION_CANCELED
+}
+
+// OpCanceledAck acknowledges OperationCanceled.
+type OpCanceledAck struct{ empty }
+
+func (*OpCanceledAck) Command() protocol.Command {
+	return protocol.OP_CANCELED_ACK
+}
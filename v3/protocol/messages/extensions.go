@@ -0,0 +1,60 @@
+package messages
+
+import (
+	"gdcl/v3/nsof"
+	"gdcl/v3/protocol"
+)
+
+func init() {
+	register(func() Message { return &RegProtocolExtension{} })
+	register(func() Message { return &RemoveProtocolExtension{} })
+}
+
+// RegProtocolExtension installs Function on the Newton as the handler of
+// the dock command Extension.
+type RegProtocolExtension struct {
+	Extension protocol.Command
+	Function  nsof.Object
+	Extra     []byte
+}
+
+func (*RegProtocolExtension) Command() protocol.Command {
+	return protocol.REG_PROTOCOL_EXTENSION
+}
+
+func (m *RegProtocolExtension) Marshal() ([]byte, error) {
+	if m.Function == nil {
+		return nil, errMissingObject
+	}
+	var w writer
+	w.long(uint32(m.Extension))
+	w.object(m.Function)
+	return append(w.data, m.Extra...), nil
+}
+
+func (m *RegProtocolExtension) Unmarshal(data []byte) error {
+	r := reader{data: data}
+	m.Extension = protocol.Command(r.long())
+	m.Function = r.object()
+	m.Extra = r.rest()
+	return r.err
+}
+
+// RemoveProtocolExtension removes the protocol extension registered for
+// the command Extension.
+type RemoveProtocolExtension struct {
+	Extension uint32
+	Extra     []byte
+}
+
+func (*RemoveProtocolExtension) Command() protocol.Command {
+	return protocol.REMOVE_PROTOCOL_EXTENSION
+}
+
+func (m *RemoveProtocolExtension) Marshal() ([]byte, error) {
+	return marshalLong(m.Extension, m.Extra)
+}
+
+func (m *RemoveProtocolExtension) Unmarshal(data []byte) error {
+	return unmarshalLong(data, &m.Extension, &m.Extra)
+}
@@ -0,0 +1,60 @@
+package messages
+
+import (
+	"gdcl/v3/protocol"
+)
+
+func init() {
+	register(func() Message { return &StartKeyboardPassthrough{} })
+	register(func() Message { return &KeyboardChar{} })
+	register(func() Message { return &KeyboardString{} })
+}
+
+// StartKeyboardPassthrough starts sending the desktop's keystrokes to the
+// Newton. Either side ends it with OperationCanceled.
+type StartKeyboardPassthrough struct{ empty }
+
+func (*StartKeyboardPassthrough) Command() protocol.Command {
+	return protocol.START_KEYBOARD_PASSTHROUGH
+}
+
+// KeyboardChar types one character on the Newton. State carries the
+// modifier keys.
+type KeyboardChar struct {
+	Char  uint16
+	State uint16
+	Extra []byte
+}
+
+func (*KeyboardChar) Command() protocol.Command {
+	return protocol.KEYBOARD_CHAR
+}
+
+func (m *KeyboardChar) Marshal() ([]byte, error) {
+	return marshalLong(uint32(m.Char)<<16|uint32(m.State), m.Extra)
+}
+
+func (m *KeyboardChar) Unmarshal(data []byte) error {
+	var value uint32
+	err := unmarshalLong(data, &value, &m.Extra)
+	m.Char, m.State = uint16(value>>16), uint16(value)
+	return err
+}
+
+// KeyboardString types a string on the Newton.
+type KeyboardString struct {
+	Text string
+}
+
+func (*KeyboardString) Command() protocol.Command {
+	return protocol.KEYBOARD_STRING
+}
+
+func (m *KeyboardString) Marshal() ([]byte, error) {
+	return writeUnicode(nil, m.Text), nil
+}
+
+func (m *KeyboardString) Unmarshal(data []byte) error {
+	m.Text = readUnicode(data)
+	return nil
+}
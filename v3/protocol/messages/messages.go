@@ -0,0 +1,291 @@
+// Package messages encodes and decodes the payloads of dock packets.
+//
+// Each dock command has a struct with Marshal and Unmarshal methods.
+// Payload bytes a struct does not know about are kept in its Extra field,
+// so they show up when the message is logged. Commands of protocol
+// extensions, which have no struct, decode to *Raw. NEWT and DOCK are the
+// packet header rather than commands.
+package messages
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"gdcl/v3/nsof"
+	"gdcl/v3/protocol"
+	"strings"
+	"unicode/utf16"
+)
+
+// Message is the payload of one dock command.
+type Message interface {
+	Command() protocol.Command
+	Marshal() ([]byte, error)
+	Unmarshal(data []byte) error
+}
+
+// Raw is the payload of a command without a specific message type.
+type Raw struct {
+	Cmd  protocol.Command
+	Data []byte
+}
+
+func (m *Raw) Command() protocol.Command {
+	return m.Cmd
+}
+
+func (m *Raw) Marshal() ([]byte, error) {
+	return m.Data, nil
+}
+
+func (m *Raw) Unmarshal(data []byte) error {
+	m.Data = data
+	return nil
+}
+
+var registry = map[protocol.Command]func() Message{}
+
+// register makes Decode return a new message from f for its command.
+func register(f func() Message) {
+	registry[f().Command()] = f
+}
+
+// Decode decodes the payload of event.
+func Decode(event *protocol.DockEvent) (Message, error) {
+	return Unmarshal(event.Command, event.Data[:min(int(event.Length), len(event.Data))])
+}
+
+// Unmarshal decodes data as the payload of command.
+func Unmarshal(command protocol.Command, data []byte) (Message, error) {
+	f, ok := registry[command]
+	if !ok {
+		return &Raw{Cmd: command, Data: data}, nil
+	}
+	message := f()
+	if err := message.Unmarshal(data); err != nil {
+		return nil, fmt.Errorf("%s: %w", command, err)
+	}
+	return message, nil
+}
+
+// Event returns an outgoing dock event carrying message.
+func Event(message Message) (*protocol.DockEvent, error) {
+	data, err := message.Marshal()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", message.Command(), err)
+	}
+	return protocol.NewDockEvent(message.Command(), protocol.Out, data), nil
+}
+
+var (
+	errShortPayload  = errors.New("payload too short")
+	errMissingObject = errors.New("missing NSOF object")
+)
+
+// reader reads the fields of a payload. After the first error, all reads
+// return zero values and err is set.
+type reader struct {
+	data []byte
+	err  error
+}
+
+func (r *reader) bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || n > len(r.data) {
+		r.err = errShortPayload
+		return nil
+	}
+	b := r.data[:n]
+	r.data = r.data[n:]
+	return b
+}
+
+func (r *reader) long() uint32 {
+	b := r.bytes(4)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint32(b)
+}
+
+func (r *reader) doubleLong() uint64 {
+	b := r.bytes(8)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint64(b)
+}
+
+// object reads an NSOF object including its version byte.
+func (r *reader) object() nsof.Object {
+	version := r.bytes(1)
+	if version == nil {
+		return nil
+	}
+	data := nsof.Data(r.data)
+	var stream nsof.ObjectStream
+	object, err := data.DecodeObject(&stream)
+	if err != nil {
+		r.err = err
+		return nil
+	}
+	r.data = data
+	return object
+}
+
+// array reads an NSOF array and returns its elements.
+func (r *reader) array() []nsof.Object {
+	object := r.object()
+	if object == nil {
+		return nil
+	}
+	array, ok := object.(*nsof.PlainArray)
+	if !ok {
+		r.err = fmt.Errorf("expected an array, got %s", object)
+		return nil
+	}
+	return array.Objects
+}
+
+// rest returns the bytes which have not been read, or nil.
+func (r *reader) rest() []byte {
+	if r.err != nil || len(r.data) == 0 {
+		return nil
+	}
+	b := r.data
+	r.data = nil
+	return b
+}
+
+// writer builds a payload.
+type writer struct {
+	data []byte
+}
+
+func (w *writer) long(value uint32) {
+	w.data = binary.BigEndian.AppendUint32(w.data, value)
+}
+
+func (w *writer) doubleLong(value uint64) {
+	w.data = binary.BigEndian.AppendUint64(w.data, value)
+}
+
+// object writes an NSOF object including its version byte.
+func (w *writer) object(object nsof.Object) {
+	data := nsof.Data(append(w.data, 2))
+	object.WriteNSOF(&data)
+	w.data = data
+}
+
+// NewString returns an NSOF string with the terminating NUL the Newton
+// expects.
+func NewString(s string) *nsof.String {
+	return &nsof.String{Value: []rune(s + "\x00")}
+}
+
+// StringValue returns the value of an NSOF string or symbol without the
+// terminating NUL, or the printed form of any other object.
+func StringValue(object nsof.Object) string {
+	switch object := object.(type) {
+	case *nsof.String:
+		return strings.TrimRight(string(object.Value), "\x00")
+	case *nsof.Symbol:
+		return object.Value
+	case nil:
+		return ""
+	}
+	return object.String()
+}
+
+// SlotString returns the string value of a slot of frame, or "" if object
+// is not a frame or has no such slot.
+func SlotString(object nsof.Object, slot string) string {
+	frame, ok := object.(*nsof.Frame)
+	if !ok {
+		return ""
+	}
+	value, err := frame.GetSlot(slot)
+	if err != nil {
+		return ""
+	}
+	return StringValue(value)
+}
+
+// readUnicode reads a NUL terminated UTF-16 string, as used outside NSOF.
+func readUnicode(data []byte) string {
+	chars := make([]uint16, 0, len(data)/2)
+	for i := 0; i+1 < len(data); i += 2 {
+		c := binary.BigEndian.Uint16(data[i:])
+		if c == 0 {
+			break
+		}
+		chars = append(chars, c)
+	}
+	return string(utf16.Decode(chars))
+}
+
+func writeUnicode(data []byte, s string) []byte {
+	for _, c := range utf16.Encode([]rune(s)) {
+		data = binary.BigEndian.AppendUint16(data, c)
+	}
+	return append(data, 0, 0)
+}
+
+// empty is embedded by the messages of commands without payload.
+type empty struct {
+	Extra []byte
+}
+
+func (m *empty) Marshal() ([]byte, error) {
+	return m.Extra, nil
+}
+
+func (m *empty) Unmarshal(data []byte) error {
+	if len(data) > 0 {
+		m.Extra = data
+	}
+	return nil
+}
+
+func marshalLong(value uint32, extra []byte) ([]byte, error) {
+	var w writer
+	w.long(value)
+	return append(w.data, extra...), nil
+}
+
+func unmarshalLong(data []byte, value *uint32, extra *[]byte) error {
+	r := reader{data: data}
+	*value = r.long()
+	*extra = r.rest()
+	return r.err
+}
+
+// marshalString encodes s as an NSOF string.
+func marshalString(s string, extra []byte) ([]byte, error) {
+	return marshalObject(NewString(s), extra)
+}
+
+func unmarshalString(data []byte, s *string, extra *[]byte) error {
+	var object nsof.Object
+	err := unmarshalObject(data, &object, extra)
+	*s = StringValue(object)
+	return err
+}
+
+func marshalObject(object nsof.Object, extra []byte) ([]byte, error) {
+	if object == nil {
+		return nil, errMissingObject
+	}
+	var w writer
+	w.object(object)
+	return append(w.data, extra...), nil
+}
+
+func unmarshalObject(data []byte, object *nsof.Object, extra *[]byte) error {
+	r := reader{data: data}
+	*object = r.object()
+	*extra = r.rest()
+	return r.err
+}
@@ -0,0 +1,297 @@
+package messages
+
+import (
+	"bytes"
+	"encoding/hex"
+	"gdcl/v3/nsof"
+	"gdcl/v3/protocol"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// objects are the values tried for nsof.Object fields, as messages expect
+// a string, a frame or an array there.
+var objects = []func() nsof.Object{
+	func() nsof.Object { return NewString("Näme") },
+	func() nsof.Object {
+		return &nsof.Frame{Slots: []nsof.Slot{{Key: &nsof.Symbol{Value: "name"}, Value: NewString("x")}}}
+	},
+	func() nsof.Object { return &nsof.PlainArray{Objects: []nsof.Object{NewString("x")}} },
+}
+
+// fill sets the fields of the struct v points to, except Extra, to
+// distinct values, using objects[choice] for NSOF objects.
+func fill(v reflect.Value, n *int, choice int) {
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Field(i)
+		name := v.Type().Field(i).Name
+		if !field.CanSet() || name == "Extra" {
+			continue
+		}
+		*n++
+		switch {
+		case field.Type() == reflect.TypeOf((*nsof.Object)(nil)).Elem():
+			field.Set(reflect.ValueOf(objects[choice]()))
+		case field.Type() == reflect.TypeOf([]nsof.Object(nil)):
+			field.Set(reflect.ValueOf([]nsof.Object{NewString("a"), &nsof.Integer{Value: int32(*n)}}))
+		case field.Type() == reflect.TypeOf([]*nsof.Frame(nil)):
+			field.Set(reflect.ValueOf([]*nsof.Frame{objects[1]().(*nsof.Frame)}))
+		case field.Kind() == reflect.String:
+			field.SetString("Näme " + strings.Repeat("x", *n))
+		case field.Kind() == reflect.Slice && field.Type().Elem().Kind() == reflect.Uint8:
+			field.SetBytes([]byte{1, 2, byte(*n)})
+		case field.Kind() == reflect.Slice:
+			slice := reflect.MakeSlice(field.Type(), 2, 2)
+			for j := 0; j < 2; j++ {
+				if element := slice.Index(j); element.Kind() == reflect.String {
+					element.SetString("Näme " + strings.Repeat("x", *n+j))
+				} else {
+					element.SetUint(uint64(*n + j))
+				}
+			}
+			field.Set(slice)
+		case field.Kind() == reflect.Struct:
+			fill(field, n, choice)
+		case field.CanUint():
+			field.SetUint(uint64(*n))
+		case field.CanInt():
+			field.SetInt(-int64(*n))
+		}
+	}
+}
+
+// TestRoundTrip marshals every registered message with all fields set and
+// checks that it decodes to the same message.
+func TestRoundTrip(t *testing.T) {
+	for command, f := range registry {
+		var errs []string
+		ok := false
+		for choice := range objects {
+			message := f()
+			if reflect.TypeOf(message).Elem().Kind() != reflect.Struct {
+				t.Fatalf("%s: %T is not a struct", command, message)
+			}
+			n := 0
+			fill(reflect.ValueOf(message).Elem(), &n, choice)
+			data, err := message.Marshal()
+			if err != nil {
+				errs = append(errs, err.Error())
+				continue
+			}
+			decoded, err := Unmarshal(command, data)
+			if err != nil {
+				errs = append(errs, err.Error())
+				continue
+			}
+			if !reflect.DeepEqual(decoded, message) {
+				errs = append(errs, "got "+hex.EncodeToString(data))
+				continue
+			}
+			ok = true
+			break
+		}
+		if !ok {
+			t.Errorf("%s: no round trip: %s", command, strings.Join(errs, "; "))
+		}
+	}
+}
+
+// TestEmptyExtra checks that payload bytes a message does not know are
+// kept in Extra and written back.
+func TestEmptyExtra(t *testing.T) {
+	data := []byte{0, 0, 0, 1, 0xde, 0xad}
+	message, err := Unmarshal(protocol.WHICH_ICONS, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (&WhichIcons{Icons: 1, Extra: []byte{0xde, 0xad}}); !reflect.DeepEqual(message, want) {
+		t.Errorf("got %+v, want %+v", message, want)
+	}
+	if again, _ := message.Marshal(); !bytes.Equal(again, data) {
+		t.Errorf("marshaled to %x, want %x", again, data)
+	}
+}
+
+func mustHex(s string) []byte {
+	data, err := hex.DecodeString(strings.Join(strings.Fields(s), ""))
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+// encodings are the payloads of the messages the dock layer, the modules
+// and the client send and receive.
+var encodings = []struct {
+	message Message
+	data    string
+}{
+	{&RequestToDock{ProtocolVersion: 10}, "0000000a"},
+	{&InitiateDocking{SessionType: 4}, "00000004"},
+	{&NewtonInfo{ProtocolVersion: 10, EncryptedKey: 0x0102030405060708}, "0000000a 0102030405060708"},
+	{&WhichIcons{Icons: 63}, "0000003f"},
+	{&SetTimeout{Seconds: 30}, "0000001e"},
+	{&Password{Key: 0x8877665544332211}, "8877665544332211"},
+	{&Result{Code: -28022}, "ffff928a"},
+	{&UnknownCommand{BadCommand: protocol.GET_APP_NAMES}, "67617070"},
+	{&ResultString{Code: -10402, Message: "Hi"}, "ffffd75e 02 08 06 0048 0069 0000"},
+	{&Hello{}, ""},
+	{&Disconnect{}, ""},
+	{&OperationDone{}, ""},
+	{&OperationCanceled{}, ""},
+	{&OpCanceledAck{}, ""},
+	{&GetStoreNames{}, ""},
+	{&SetCurrentStore{Store: &nsof.Frame{Slots: []nsof.Slot{
+		{Key: &nsof.Symbol{Value: "name"}, Value: NewString("A")},
+	}}}, "02 06 01 07 04 6e616d65 08 04 0041 0000"},
+	{&GetSoupNames{}, ""},
+	{&GetAppNames{What: AppNamesAndSoupsAllStores}, "00000000"},
+	{&RequestToInstall{}, ""},
+	{&LoadPackage{Package: []byte("package0")}, "7061636b61676530"},
+	{&NewtonName{Info: VersionInfo{NewtonID: 1, SerialNumber: 2, TargetProtocol: 10}, Name: "Né"},
+		"00000048 00000001" + strings.Repeat("00000000", 14) + "0000000000000002 0000000a 004e 00e9 0000"},
+}
+
+func TestEncodings(t *testing.T) {
+	for _, test := range encodings {
+		want := mustHex(test.data)
+		data, err := test.message.Marshal()
+		if err != nil || !bytes.Equal(data, want) {
+			t.Errorf("%s: marshaled to %x, %v, want %x", test.message.Command(), data, err, want)
+		}
+		decoded, err := Unmarshal(test.message.Command(), want)
+		if err != nil || !reflect.DeepEqual(decoded, test.message) {
+			t.Errorf("%s: decoded to %+v, %v, want %+v", test.message.Command(), decoded, err, test.message)
+		}
+	}
+}
+
+// desktopInfo is the DESKTOP_INFO gdcl sent before the message types
+// existed, as accepted by real Newtons.
+var desktopInfo = mustHex(`
+	00000002 00000000 6423ef02fbcdc5a5 00000001 00000001
+	02 05 01 06 03 07 02 6964 07 04 6e616d65 07 07 76657273696f6e
+	00 08 08 38 004e 0065 0077 0074 006f 006e 0020 0043 006f 006e 006e
+	0065 0063 0074 0069 006f 006e 0020 0055 0074 0069 006c 0069 0074
+	0069 0065 0073 0000 00 04`)
+
+func TestDecodeDesktopInfo(t *testing.T) {
+	message, err := Unmarshal(protocol.DESKTOP_INFO, desktopInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := message.(*DesktopInfo)
+	if info.ProtocolVersion != 2 || info.DesktopType != 0 || info.EncryptedKey != 0x6423ef02fbcdc5a5 ||
+		info.SessionType != 1 || info.AllowSelectiveSync != 1 || info.Extra != nil {
+		t.Errorf("got %+v", info)
+	}
+	apps, ok := info.DesktopApps.(*nsof.PlainArray)
+	if !ok || len(apps.Objects) != 1 {
+		t.Fatalf("got desktop apps %s", info.DesktopApps)
+	}
+	if name := SlotString(apps.Objects[0], "name"); name != "Newton Connection Utilities" {
+		t.Errorf("got name %q", name)
+	}
+	if data, err := message.Marshal(); err != nil || !bytes.Equal(data, desktopInfo) {
+		t.Errorf("marshaled to %x, %v, want %x", data, err, desktopInfo)
+	}
+}
+
+func TestDecodeNewtonName(t *testing.T) {
+	tests := []struct {
+		name  string
+		data  string
+		want  NewtonName
+		extra int
+	}{
+		{
+			// Newton OS 2.1 sends all fields of VersionInfo.
+			name: "full",
+			data: `00000048 1234abcd 01000000 10003000 00020001 00008000 00400000
+				000001e0 00000140 00000100 00020001 00009876 00000055 00000055
+				00000004 00000001 deadbeef01020304 0000000a 0046 0061 006b 0065 0000`,
+			want: NewtonName{Info: VersionInfo{
+				NewtonID: 0x1234abcd, Manufacturer: 0x01000000, MachineType: 0x10003000,
+				ROMVersion: 0x00020001, ROMStage: 0x8000, RAMSize: 4194304,
+				ScreenHeight: 480, ScreenWidth: 320, PatchVersion: 0x100, NOSVersion: 0x00020001,
+				InternalStoreSignature: 0x9876, ScreenResolutionV: 85, ScreenResolutionH: 85,
+				ScreenDepth: 4, SystemFlags: 1, SerialNumber: 0xdeadbeef01020304, TargetProtocol: 10,
+			}, Name: "Fake"},
+		},
+		{
+			// Older Newtons send fewer fields.
+			name: "short",
+			data: "00000008 1234abcd 01000000 004d 0050 0000",
+			want: NewtonName{Info: VersionInfo{NewtonID: 0x1234abcd, Manufacturer: 0x01000000}, Name: "MP"},
+		},
+		{
+			// Fields of later versions are kept in Extra.
+			name: "long",
+			data: "0000004c 00000001" + strings.Repeat("00000000", 14) + "0000000000000000 0000000a 77777777 0041 0000",
+			want: NewtonName{Info: VersionInfo{NewtonID: 1, TargetProtocol: 10}, Name: "A", Extra: []byte{0x77, 0x77, 0x77, 0x77}},
+		},
+	}
+	for _, test := range tests {
+		message, err := Unmarshal(protocol.NEWTON_NAME, mustHex(test.data))
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(message, &test.want) {
+			t.Errorf("%s: got %+v, want %+v", test.name, message, &test.want)
+		}
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tests := []struct {
+		command protocol.Command
+		data    string
+	}{
+		{protocol.RESULT, "0000"},
+		{protocol.NEWTON_INFO, "0000000a 01020304"},
+		{protocol.NEWTON_NAME, "00000048 1234abcd"},
+		{protocol.STORE_NAMES, "02 05 02 08"},
+		{protocol.STORE_NAMES, "02 08 02 0041"},
+		{protocol.RESULT_STRING, "ffffd75e"},
+	}
+	for _, test := range tests {
+		if message, err := Unmarshal(test.command, mustHex(test.data)); err == nil {
+			t.Errorf("%s %s: got %+v, want an error", test.command, test.data, message)
+		}
+	}
+}
+
+func TestDecodeResults(t *testing.T) {
+	event := protocol.NewDockEvent(protocol.RESULT, protocol.In, mustHex("ffff928a"))
+	if err := ResultErr(event); err != protocol.ErrBadPassword || !ResultFailed(event) {
+		t.Errorf("got %v, want %v", err, protocol.ErrBadPassword)
+	}
+	event = protocol.NewDockEvent(protocol.RESULT, protocol.In, mustHex("00000000"))
+	if err := ResultErr(event); err != nil || ResultFailed(event) {
+		t.Errorf("got %v, want nil", err)
+	}
+	event = protocol.NewDockEvent(protocol.RESULT, protocol.In, mustHex("00"))
+	if _, ok := ResultErr(event).(*protocol.DockError); !ok || !ResultFailed(event) {
+		t.Errorf("got %v, want a DockError", ResultErr(event))
+	}
+}
+
+func TestAppNames(t *testing.T) {
+	frame := func(name string) nsof.Object {
+		return &nsof.Frame{Slots: []nsof.Slot{{Key: &nsof.Symbol{Value: "name"}, Value: NewString(name)}}}
+	}
+	message := &AppNames{Apps: []nsof.Object{frame("Notes"), frame("Dates"), NewString("Calls")}}
+	data, err := message.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := Unmarshal(protocol.APP_NAMES, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := decoded.(*AppNames).Names(), []string{"Notes", "Dates", "Calls"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,268 @@
+package messages
+
+import (
+	"fmt"
+	"gdcl/v3/nsof"
+	"gdcl/v3/protocol"
+)
+
+func init() {
+	register(func() Message { return &GetAppNames{} })
+	register(func() Message { return &AppNames{} })
+	register(func() Message { return &RequestToInstall{} })
+	register(func() Message { return &LoadPackage{} })
+	register(func() Message { return &GetPackageIDs{} })
+	register(func() Message { return &BackupPackages{} })
+	register(func() Message { return &DeleteAllPackages{} })
+	register(func() Message { return &GetPackageInfo{} })
+	register(func() Message { return &PackageInfo{} })
+	register(func() Message { return &CallGlobalFunction{} })
+	register(func() Message { return &CallRootMethod{} })
+	register(func() Message { return &CallResult{} })
+	register(func() Message { return &RemovePackage{} })
+}
+
+// Values of GetAppNames.What
+const (
+	AppNamesAndSoupsAllStores uint32 = iota
+	AppNamesAndSoupsCurrentStore
+	AppNamesAllStores
+	AppNamesCurrentStore
+)
+
+// GetAppNames asks for the applications on the Newton.
+type GetAppNames struct {
+	What  uint32
+	Extra []byte
+}
+
+func (*GetAppNames) Command() protocol.Command {
+	return protocol.GET_APP_NAMES
+}
+
+func (m *GetAppNames) Marshal() ([]byte, error) {
+	return marshalLong(m.What, m.Extra)
+}
+
+func (m *GetAppNames) Unmarshal(data []byte) error {
+	return unmarshalLong(data, &m.What, &m.Extra)
+}
+
+// AppNames is the answer to GetAppNames. Depending on GetAppNames.What,
+// the elements are frames with the name and soups of an application or
+// just the names.
+type AppNames struct {
+	Apps  []nsof.Object
+	Extra []byte
+}
+
+func (*AppNames) Command() protocol.Command {
+	return protocol.APP_NAMES
+}
+
+func (m *AppNames) Marshal() ([]byte, error) {
+	return marshalObject(&nsof.PlainArray{Objects: m.Apps}, m.Extra)
+}
+
+func (m *AppNames) Unmarshal(data []byte) error {
+	r := reader{data: data}
+	m.Apps = r.array()
+	m.Extra = r.rest()
+	return r.err
+}
+
+// Names returns the names of the applications.
+func (m *AppNames) Names() []string {
+	var names []string
+	for _, app := range m.Apps {
+		if _, ok := app.(*nsof.Frame); ok {
+			names = append(names, SlotString(app, "name"))
+		} else {
+			names = append(names, StringValue(app))
+		}
+	}
+	return names
+}
+
+// RequestToInstall announces a LoadPackage.
+type RequestToInstall struct{ empty }
+
+func (*RequestToInstall) Command() protocol.Command {
+	return protocol.REQUEST_TO_INSTALL
+}
+
+// LoadPackage installs a package.
+type LoadPackage struct {
+	Package []byte
+}
+
+func (*LoadPackage) Command() protocol.Command {
+	return protocol.LOAD_PACKAGE
+}
+
+func (m *LoadPackage) Marshal() ([]byte, error) {
+	return m.Package, nil
+}
+
+func (m *LoadPackage) Unmarshal(data []byte) error {
+	m.Package = data
+	return nil
+}
+
+// GetPackageIDs asks for the packages on the current store.
+type GetPackageIDs struct{ empty }
+
+func (*GetPackageIDs) Command() protocol.Command {
+	return protocol.GET_PACKAGE_IDS
+}
+
+// BackupPackages asks the Newton to send the packages of the current
+// store.
+type BackupPackages struct{ empty }
+
+func (*BackupPackages) Command() protocol.Command {
+	return protocol.BACKUP_PACKAGES
+}
+
+// DeleteAllPackages deletes the packages of the current store.
+type DeleteAllPackages struct{ empty }
+
+func (*DeleteAllPackages) Command() protocol.Command {
+	return protocol.DELETE_ALL_PACKAGES
+}
+
+// RemovePackage removes a package by name.
+type RemovePackage struct {
+	Name  string
+	Extra []byte
+}
+
+func (*RemovePackage) Command() protocol.Command {
+	return protocol.REMOVE_PACKAGE
+}
+
+func (m *RemovePackage) Marshal() ([]byte, error) {
+	return marshalObject(NewString(m.Name), m.Extra)
+}
+
+func (m *RemovePackage) Unmarshal(data []byte) error {
+	var name nsof.Object
+	err := unmarshalObject(data, &name, &m.Extra)
+	m.Name = StringValue(name)
+	return err
+}
+
+// GetPackageInfo asks for the description of a package by name. The
+// Newton answers with PackageInfo.
+type GetPackageInfo struct {
+	Name  string
+	Extra []byte
+}
+
+func (*GetPackageInfo) Command() protocol.Command {
+	return protocol.GET_PACKAGE_INFO
+}
+
+func (m *GetPackageInfo) Marshal() ([]byte, error) {
+	return marshalString(m.Name, m.Extra)
+}
+
+func (m *GetPackageInfo) Unmarshal(data []byte) error {
+	return unmarshalString(data, &m.Name, &m.Extra)
+}
+
+// PackageInfo describes packages on the Newton.
+type PackageInfo struct {
+	Info  nsof.Object
+	Extra []byte
+}
+
+func (*PackageInfo) Command() protocol.Command {
+	return protocol.PACKAGE_INFO
+}
+
+func (m *PackageInfo) Marshal() ([]byte, error) {
+	return marshalObject(m.Info, m.Extra)
+}
+
+func (m *PackageInfo) Unmarshal(data []byte) error {
+	return unmarshalObject(data, &m.Info, &m.Extra)
+}
+
+// marshalCall encodes the payload of CallGlobalFunction and CallRootMethod:
+// the function name as a symbol and the arguments as an array.
+func marshalCall(function string, args []nsof.Object, extra []byte) ([]byte, error) {
+	var w writer
+	w.object(&nsof.Symbol{Value: function})
+	w.object(&nsof.PlainArray{Objects: args})
+	return append(w.data, extra...), nil
+}
+
+func unmarshalCall(data []byte, function *string, args *[]nsof.Object, extra *[]byte) error {
+	r := reader{data: data}
+	name := r.object()
+	if symbol, ok := name.(*nsof.Symbol); ok {
+		*function = symbol.Value
+	} else if r.err == nil {
+		return fmt.Errorf("expected a function name, got %s", name)
+	}
+	*args = r.array()
+	*extra = r.rest()
+	return r.err
+}
+
+// CallGlobalFunction calls a global function on the Newton.
+type CallGlobalFunction struct {
+	Function string
+	Args     []nsof.Object
+	Extra    []byte
+}
+
+func (*CallGlobalFunction) Command() protocol.Command {
+	return protocol.CALL_GLOBAL_FUNCTION
+}
+
+func (m *CallGlobalFunction) Marshal() ([]byte, error) {
+	return marshalCall(m.Function, m.Args, m.Extra)
+}
+
+func (m *CallGlobalFunction) Unmarshal(data []byte) error {
+	return unmarshalCall(data, &m.Function, &m.Args, &m.Extra)
+}
+
+// CallRootMethod calls a method of the root view on the Newton.
+type CallRootMethod struct {
+	Function string
+	Args     []nsof.Object
+	Extra    []byte
+}
+
+func (*CallRootMethod) Command() protocol.Command {
+	return protocol.CALL_ROOT_METHOD
+}
+
+func (m *CallRootMethod) Marshal() ([]byte, error) {
+	return marshalCall(m.Function, m.Args, m.Extra)
+}
+
+func (m *CallRootMethod) Unmarshal(data []byte) error {
+	return unmarshalCall(data, &m.Function, &m.Args, &m.Extra)
+}
+
+// CallResult is the answer to CallGlobalFunction and CallRootMethod.
+type CallResult struct {
+	Result nsof.Object
+	Extra  []byte
+}
+
+func (*CallResult) Command() protocol.Command {
+	return protocol.CALL_RESULT
+}
+
+func (m *CallResult) Marshal() ([]byte, error) {
+	return marshalObject(m.Result, m.Extra)
+}
+
+func (m *CallResult) Unmarshal(data []byte) error {
+	return unmarshalObject(data, &m.Result, &m.Extra)
+}
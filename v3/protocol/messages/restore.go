@@ -0,0 +1,178 @@
+package messages
+
+import (
+	"gdcl/v3/nsof"
+	"gdcl/v3/protocol"
+)
+
+func init() {
+	register(func() Message { return &GetRestoreOptions{} })
+	register(func() Message { return &RestoreOptions{} })
+	register(func() Message { return &RestoreAll{} })
+	register(func() Message { return &RestoreFile{} })
+	register(func() Message { return &RestorePackage{} })
+	register(func() Message { return &PackageIDList{} })
+	register(func() Message { return &Package{} })
+	register(func() Message { return &RestorePatch{} })
+	register(func() Message { return &GetPatches{} })
+	register(func() Message { return &Patches{} })
+	register(func() Message { return &DeletePkgDir{} })
+}
+
+// GetRestoreOptions asks for the restore options the user picked on the
+// Newton.
+type GetRestoreOptions struct{ empty }
+
+func (*GetRestoreOptions) Command() protocol.Command {
+	return protocol.GET_RESTORE_OPTIONS
+}
+
+// RestoreOptions is the answer to GetRestoreOptions.
+type RestoreOptions struct {
+	Options nsof.Object
+	Extra   []byte
+}
+
+func (*RestoreOptions) Command() protocol.Command {
+	return protocol.RESTORE_OPTIONS
+}
+
+func (m *RestoreOptions) Marshal() ([]byte, error) {
+	return marshalObject(m.Options, m.Extra)
+}
+
+func (m *RestoreOptions) Unmarshal(data []byte) error {
+	return unmarshalObject(data, &m.Options, &m.Extra)
+}
+
+// RestoreAll tells the Newton that everything in the backup is restored.
+type RestoreAll struct{ empty }
+
+func (*RestoreAll) Command() protocol.Command {
+	return protocol.RESTORE_ALL
+}
+
+// RestoreFile selects the backup file to restore from, as an NSOF path.
+type RestoreFile struct {
+	Path  nsof.Object
+	Extra []byte
+}
+
+func (*RestoreFile) Command() protocol.Command {
+	return protocol.RESTORE_FILE
+}
+
+func (m *RestoreFile) Marshal() ([]byte, error) {
+	return marshalObject(m.Path, m.Extra)
+}
+
+func (m *RestoreFile) Unmarshal(data []byte) error {
+	return unmarshalObject(data, &m.Path, &m.Extra)
+}
+
+// RestorePackage lists the packages to restore.
+type RestorePackage struct {
+	Packages nsof.Object
+	Extra    []byte
+}
+
+func (*RestorePackage) Command() protocol.Command {
+	return protocol.RESTORE_PACKAGE
+}
+
+func (m *RestorePackage) Marshal() ([]byte, error) {
+	return marshalObject(m.Packages, m.Extra)
+}
+
+func (m *RestorePackage) Unmarshal(data []byte) error {
+	return unmarshalObject(data, &m.Packages, &m.Extra)
+}
+
+// PackageIDList lists the packages in a backup, for the user to pick the
+// ones to restore.
+type PackageIDList struct {
+	Packages nsof.Object
+	Extra    []byte
+}
+
+func (*PackageIDList) Command() protocol.Command {
+	return protocol.PACKAGE_ID_LIST
+}
+
+func (m *PackageIDList) Marshal() ([]byte, error) {
+	return marshalObject(m.Packages, m.Extra)
+}
+
+func (m *PackageIDList) Unmarshal(data []byte) error {
+	return unmarshalObject(data, &m.Packages, &m.Extra)
+}
+
+// Package is a package sent by the Newton during a backup, preceded by
+// its ID.
+type Package struct {
+	ID      uint32
+	Package []byte
+}
+
+func (*Package) Command() protocol.Command {
+	return protocol.PACKAGE
+}
+
+func (m *Package) Marshal() ([]byte, error) {
+	return marshalLong(m.ID, m.Package)
+}
+
+func (m *Package) Unmarshal(data []byte) error {
+	return unmarshalLong(data, &m.ID, &m.Package)
+}
+
+// RestorePatch sends a system update from a backup to the Newton.
+type RestorePatch struct {
+	Patch nsof.Object
+	Extra []byte
+}
+
+func (*RestorePatch) Command() protocol.Command {
+	return protocol.RESTORE_PATCH
+}
+
+func (m *RestorePatch) Marshal() ([]byte, error) {
+	return marshalObject(m.Patch, m.Extra)
+}
+
+func (m *RestorePatch) Unmarshal(data []byte) error {
+	return unmarshalObject(data, &m.Patch, &m.Extra)
+}
+
+// GetPatches asks for the system updates installed on the Newton.
+type GetPatches struct{ empty }
+
+func (*GetPatches) Command() protocol.Command {
+	return protocol.GET_PATCHES
+}
+
+// Patches is the answer to GetPatches.
+type Patches struct {
+	Patches nsof.Object
+	Extra   []byte
+}
+
+func (*Patches) Command() protocol.Command {
+	return protocol.PATCHES
+}
+
+func (m *Patches) Marshal() ([]byte, error) {
+	return marshalObject(m.Patches, m.Extra)
+}
+
+func (m *Patches) Unmarshal(data []byte) error {
+	return unmarshalObject(data, &m.Patches, &m.Extra)
+}
+
+// DeletePkgDir deletes the packages of the current store before they are
+// restored.
+type DeletePkgDir struct{ empty }
+
+func (*DeletePkgDir) Command() protocol.Command {
+	return protocol.DELETE_PKG_DIR
+}
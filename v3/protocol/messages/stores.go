@@ -0,0 +1,633 @@
+package messages
+
+import (
+	"fmt"
+	"gdcl/v3/nsof"
+	"gdcl/v3/protocol"
+)
+
+func init() {
+	register(func() Message { return &GetStoreNames{} })
+	register(func() Message { return &StoreNames{} })
+	register(func() Message { return &GetDefaultStore{} })
+	register(func() Message { return &DefaultStore{} })
+	register(func() Message { return &SetCurrentStore{} })
+	register(func() Message { return &GetSoupNames{} })
+	register(func() Message { return &SoupNames{} })
+	register(func() Message { return &SetCurrentSoup{} })
+	register(func() Message { return &GetSoupInfo{} })
+	register(func() Message { return &SoupInfo{} })
+	register(func() Message { return &GetSoupIDs{} })
+	register(func() Message { return &SoupIDs{} })
+	register(func() Message { return &ChangedIDs{} })
+	register(func() Message { return &ReturnEntry{} })
+	register(func() Message { return &Entry{} })
+	register(func() Message { return &AddEntry{} })
+	register(func() Message { return &AddedID{} })
+	register(func() Message { return &DeleteEntries{} })
+	register(func() Message { return &EmptySoup{} })
+	register(func() Message { return &DeleteSoup{} })
+	register(func() Message { return &SetBaseID{} })
+	register(func() Message { return &CurrentTime{} })
+	register(func() Message { return &LastSyncTime{} })
+	register(func() Message { return &RequestToSync{} })
+	register(func() Message { return &RequestToRestore{} })
+	register(func() Message { return &GetInternalStore{} })
+	register(func() Message { return &InternalStore{} })
+	register(func() Message { return &SetStoreToDefault{} })
+	register(func() Message { return &SetStoreGetNames{} })
+	register(func() Message { return &SetSoupGetInfo{} })
+	register(func() Message { return &CreateSoup{} })
+	register(func() Message { return &CreateDefaultSoup{} })
+	register(func() Message { return &SoupNotDirty{} })
+	register(func() Message { return &GetChangedIndex{} })
+	register(func() Message { return &GetChangedInfo{} })
+	register(func() Message { return &GetInheritance{} })
+	register(func() Message { return &Inheritance{} })
+}
+
+// GetStoreNames asks for the stores of the Newton.
+type GetStoreNames struct{ empty }
+
+func (*GetStoreNames) Command() protocol.Command {
+	return protocol.GET_STORE_NAMES
+}
+
+// StoreNames is the answer to GetStoreNames, a frame per store with its
+// name, signature, kind and so on.
+type StoreNames struct {
+	Stores []*nsof.Frame
+	Extra  []byte
+}
+
+func (*StoreNames) Command() protocol.Command {
+	return protocol.STORE_NAMES
+}
+
+func (m *StoreNames) Marshal() ([]byte, error) {
+	array := &nsof.PlainArray{}
+	for _, store := range m.Stores {
+		array.Objects = append(array.Objects, store)
+	}
+	return marshalObject(array, m.Extra)
+}
+
+func (m *StoreNames) Unmarshal(data []byte) error {
+	r := reader{data: data}
+	for _, object := range r.array() {
+		frame, ok := object.(*nsof.Frame)
+		if !ok {
+			return fmt.Errorf("expected a store frame, got %s", object)
+		}
+		m.Stores = append(m.Stores, frame)
+	}
+	m.Extra = r.rest()
+	return r.err
+}
+
+// GetDefaultStore asks for the store new entries go to.
+type GetDefaultStore struct{ empty }
+
+func (*GetDefaultStore) Command() protocol.Command {
+	return protocol.GET_DEFAULT_STORE
+}
+
+// DefaultStore is the answer to GetDefaultStore.
+type DefaultStore struct {
+	Store nsof.Object
+	Extra []byte
+}
+
+func (*DefaultStore) Command() protocol.Command {
+	return protocol.DEFAULT_STORE
+}
+
+func (m *DefaultStore) Marshal() ([]byte, error) {
+	return marshalObject(m.Store, m.Extra)
+}
+
+func (m *DefaultStore) Unmarshal(data []byte) error {
+	return unmarshalObject(data, &m.Store, &m.Extra)
+}
+
+// SetCurrentStore selects the store the following soup commands work on.
+// Store is one of the frames of StoreNames.
+type SetCurrentStore struct {
+	Store nsof.Object
+	Extra []byte
+}
+
+func (*SetCurrentStore) Command() protocol.Command {
+	return protocol.SET_CURRENT_STORE
+}
+
+func (m *SetCurrentStore) Marshal() ([]byte, error) {
+	return marshalObject(m.Store, m.Extra)
+}
+
+func (m *SetCurrentStore) Unmarshal(data []byte) error {
+	return unmarshalObject(data, &m.Store, &m.Extra)
+}
+
+// GetSoupNames asks for the soups of the current store.
+type GetSoupNames struct{ empty }
+
+func (*GetSoupNames) Command() protocol.Command {
+	return protocol.GET_SOUP_NAMES
+}
+
+// SoupNames is the answer to GetSoupNames. Signatures is empty if the
+// Newton did not send any.
+type SoupNames struct {
+	Names      []string
+	Signatures []nsof.Object
+	Extra      []byte
+}
+
+func (*SoupNames) Command() protocol.Command {
+	return protocol.SOUP_NAMES
+}
+
+func (m *SoupNames) Marshal() ([]byte, error) {
+	names := &nsof.PlainArray{}
+	for _, name := range m.Names {
+		names.Objects = append(names.Objects, NewString(name))
+	}
+	var w writer
+	w.object(names)
+	if len(m.Signatures) > 0 {
+		w.object(&nsof.PlainArray{Objects: m.Signatures})
+	}
+	return append(w.data, m.Extra...), nil
+}
+
+func (m *SoupNames) Unmarshal(data []byte) error {
+	r := reader{data: data}
+	for _, name := range r.array() {
+		m.Names = append(m.Names, StringValue(name))
+	}
+	if r.err == nil && len(r.data) > 0 {
+		m.Signatures = r.array()
+	}
+	m.Extra = r.rest()
+	return r.err
+}
+
+// SetCurrentSoup selects a soup of the current store.
+type SetCurrentSoup struct {
+	Name  string
+	Extra []byte
+}
+
+func (*SetCurrentSoup) Command() protocol.Command {
+	return protocol.SET_CURRENT_SOUP
+}
+
+func (m *SetCurrentSoup) Marshal() ([]byte, error) {
+	return marshalObject(NewString(m.Name), m.Extra)
+}
+
+func (m *SetCurrentSoup) Unmarshal(data []byte) error {
+	var name nsof.Object
+	err := unmarshalObject(data, &name, &m.Extra)
+	m.Name = StringValue(name)
+	return err
+}
+
+// GetSoupInfo asks for the info frame of the current soup.
+type GetSoupInfo struct{ empty }
+
+func (*GetSoupInfo) Command() protocol.Command {
+	return protocol.GET_SOUP_INFO
+}
+
+// SoupInfo is the answer to GetSoupInfo.
+type SoupInfo struct {
+	Info  nsof.Object
+	Extra []byte
+}
+
+func (*SoupInfo) Command() protocol.Command {
+	return protocol.SOUP_INFO
+}
+
+func (m *SoupInfo) Marshal() ([]byte, error) {
+	return marshalObject(m.Info, m.Extra)
+}
+
+func (m *SoupInfo) Unmarshal(data []byte) error {
+	return unmarshalObject(data, &m.Info, &m.Extra)
+}
+
+// GetSoupIDs asks for the IDs of the entries in the current soup.
+type GetSoupIDs struct{ empty }
+
+func (*GetSoupIDs) Command() protocol.Command {
+	return protocol.GET_SOUP_IDS
+}
+
+// marshalIDs encodes the payload of the commands with a list of entry IDs:
+// a count followed by the IDs.
+func marshalIDs(ids []uint32, extra []byte) ([]byte, error) {
+	var w writer
+	w.long(uint32(len(ids)))
+	for _, id := range ids {
+		w.long(id)
+	}
+	return append(w.data, extra...), nil
+}
+
+func unmarshalIDs(data []byte, ids *[]uint32, extra *[]byte) error {
+	r := reader{data: data}
+	count := r.long()
+	if r.err == nil && int(count) > len(r.data)/4 {
+		return errShortPayload
+	}
+	for i := uint32(0); i < count; i++ {
+		*ids = append(*ids, r.long())
+	}
+	*extra = r.rest()
+	return r.err
+}
+
+// SoupIDs is the answer to GetSoupIDs.
+type SoupIDs struct {
+	IDs   []uint32
+	Extra []byte
+}
+
+func (*SoupIDs) Command() protocol.Command {
+	return protocol.SOUP_IDS
+}
+
+func (m *SoupIDs) Marshal() ([]byte, error) {
+	return marshalIDs(m.IDs, m.Extra)
+}
+
+func (m *SoupIDs) Unmarshal(data []byte) error {
+	return unmarshalIDs(data, &m.IDs, &m.Extra)
+}
+
+// ChangedIDs lists the entries of the current soup changed since the last
+// sync.
+type ChangedIDs struct {
+	IDs   []uint32
+	Extra []byte
+}
+
+func (*ChangedIDs) Command() protocol.Command {
+	return protocol.CHANGED_IDS
+}
+
+func (m *ChangedIDs) Marshal() ([]byte, error) {
+	return marshalIDs(m.IDs, m.Extra)
+}
+
+func (m *ChangedIDs) Unmarshal(data []byte) error {
+	return unmarshalIDs(data, &m.IDs, &m.Extra)
+}
+
+// DeleteEntries deletes entries of the current soup.
+type DeleteEntries struct {
+	IDs   []uint32
+	Extra []byte
+}
+
+func (*DeleteEntries) Command() protocol.Command {
+	return protocol.DELETE_ENTRIES
+}
+
+func (m *DeleteEntries) Marshal() ([]byte, error) {
+	return marshalIDs(m.IDs, m.Extra)
+}
+
+func (m *DeleteEntries) Unmarshal(data []byte) error {
+	return unmarshalIDs(data, &m.IDs, &m.Extra)
+}
+
+// ReturnEntry asks for an entry of the current soup.
+type ReturnEntry struct {
+	ID    uint32
+	Extra []byte
+}
+
+func (*ReturnEntry) Command() protocol.Command {
+	return protocol.RETURN_ENTRY
+}
+
+func (m *ReturnEntry) Marshal() ([]byte, error) {
+	return marshalLong(m.ID, m.Extra)
+}
+
+func (m *ReturnEntry) Unmarshal(data []byte) error {
+	return unmarshalLong(data, &m.ID, &m.Extra)
+}
+
+// Entry is a soup entry.
+type Entry struct {
+	Entry nsof.Object
+	Extra []byte
+}
+
+func (*Entry) Command() protocol.Command {
+	return protocol.ENTRY
+}
+
+func (m *Entry) Marshal() ([]byte, error) {
+	return marshalObject(m.Entry, m.Extra)
+}
+
+func (m *Entry) Unmarshal(data []byte) error {
+	return unmarshalObject(data, &m.Entry, &m.Extra)
+}
+
+// AddEntry adds an entry to the current soup.
+type AddEntry struct {
+	Entry nsof.Object
+	Extra []byte
+}
+
+func (*AddEntry) Command() protocol.Command {
+	return protocol.ADD_ENTRY
+}
+
+func (m *AddEntry) Marshal() ([]byte, error) {
+	return marshalObject(m.Entry, m.Extra)
+}
+
+func (m *AddEntry) Unmarshal(data []byte) error {
+	return unmarshalObject(data, &m.Entry, &m.Extra)
+}
+
+// AddedID is the answer to AddEntry.
+type AddedID struct {
+	ID    uint32
+	Extra []byte
+}
+
+func (*AddedID) Command() protocol.Command {
+	return protocol.ADDED_ID
+}
+
+func (m *AddedID) Marshal() ([]byte, error) {
+	return marshalLong(m.ID, m.Extra)
+}
+
+func (m *AddedID) Unmarshal(data []byte) error {
+	return unmarshalLong(data, &m.ID, &m.Extra)
+}
+
+// EmptySoup deletes all entries of the current soup.
+type EmptySoup struct{ empty }
+
+func (*EmptySoup) Command() protocol.Command {
+	return protocol.EMPTY_SOUP
+}
+
+// DeleteSoup deletes the current soup.
+type DeleteSoup struct{ empty }
+
+func (*DeleteSoup) Command() protocol.Command {
+	return protocol.DELETE_SOUP
+}
+
+// SetBaseID sets the ID the entry IDs of the current soup are relative to.
+type SetBaseID struct {
+	ID    uint32
+	Extra []byte
+}
+
+func (*SetBaseID) Command() protocol.Command {
+	return protocol.SET_BASE_ID
+}
+
+func (m *SetBaseID) Marshal() ([]byte, error) {
+	return marshalLong(m.ID, m.Extra)
+}
+
+func (m *SetBaseID) Unmarshal(data []byte) error {
+	return unmarshalLong(data, &m.ID, &m.Extra)
+}
+
+// CurrentTime is the time on the Newton in minutes since 1904.
+type CurrentTime struct {
+	Minutes uint32
+	Extra   []byte
+}
+
+func (*CurrentTime) Command() protocol.Command {
+	return protocol.CURRENT_TIME
+}
+
+func (m *CurrentTime) Marshal() ([]byte, error) {
+	return marshalLong(m.Minutes, m.Extra)
+}
+
+func (m *CurrentTime) Unmarshal(data []byte) error {
+	return unmarshalLong(data, &m.Minutes, &m.Extra)
+}
+
+// LastSyncTime is the time of the last sync in minutes since 1904.
+type LastSyncTime struct {
+	Minutes uint32
+	Extra   []byte
+}
+
+func (*LastSyncTime) Command() protocol.Command {
+	return protocol.LAST_SYNC_TIME
+}
+
+func (m *LastSyncTime) Marshal() ([]byte, error) {
+	return marshalLong(m.Minutes, m.Extra)
+}
+
+func (m *LastSyncTime) Unmarshal(data []byte) error {
+	return unmarshalLong(data, &m.Minutes, &m.Extra)
+}
+
+// RequestToSync is sent by the Newton to start a synchronization.
+type RequestToSync struct{ empty }
+
+func (*RequestToSync) Command() protocol.Command {
+	return protocol.REQUEST_TO_SYNC
+}
+
+// RequestToRestore is sent by the Newton to start a restore.
+type RequestToRestore struct{ empty }
+
+func (*RequestToRestore) Command() protocol.Command {
+	return protocol.REQUEST_TO_RESTORE
+}
+
+// GetInternalStore asks for the internal store of the Newton.
+type GetInternalStore struct{ empty }
+
+func (*GetInternalStore) Command() protocol.Command {
+	return protocol.GET_INTERNAL_STORE
+}
+
+// InternalStore is the answer to GetInternalStore.
+type InternalStore struct {
+	Store nsof.Object
+	Extra []byte
+}
+
+func (*InternalStore) Command() protocol.Command {
+	return protocol.INTERNAL_STORE
+}
+
+func (m *InternalStore) Marshal() ([]byte, error) {
+	return marshalObject(m.Store, m.Extra)
+}
+
+func (m *InternalStore) Unmarshal(data []byte) error {
+	return unmarshalObject(data, &m.Store, &m.Extra)
+}
+
+// SetStoreToDefault makes the default store the current store.
+type SetStoreToDefault struct{ empty }
+
+func (*SetStoreToDefault) Command() protocol.Command {
+	return protocol.SET_STORE_TO_DEFAULT
+}
+
+// SetStoreGetNames selects a store like SetCurrentStore and is answered
+// with its SoupNames.
+type SetStoreGetNames struct {
+	Store nsof.Object
+	Extra []byte
+}
+
+func (*SetStoreGetNames) Command() protocol.Command {
+	return protocol.SET_STORE_GET_NAMES
+}
+
+func (m *SetStoreGetNames) Marshal() ([]byte, error) {
+	return marshalObject(m.Store, m.Extra)
+}
+
+func (m *SetStoreGetNames) Unmarshal(data []byte) error {
+	return unmarshalObject(data, &m.Store, &m.Extra)
+}
+
+// SetSoupGetInfo selects a soup like SetCurrentSoup and is answered with
+// its SoupInfo.
+type SetSoupGetInfo struct {
+	Name  string
+	Extra []byte
+}
+
+func (*SetSoupGetInfo) Command() protocol.Command {
+	return protocol.SET_SOUP_GET_INFO
+}
+
+func (m *SetSoupGetInfo) Marshal() ([]byte, error) {
+	return marshalObject(NewString(m.Name), m.Extra)
+}
+
+func (m *SetSoupGetInfo) Unmarshal(data []byte) error {
+	var name nsof.Object
+	err := unmarshalObject(data, &name, &m.Extra)
+	m.Name = StringValue(name)
+	return err
+}
+
+// CreateSoup creates a soup on the current store.
+type CreateSoup struct {
+	Name    string
+	Indexes nsof.Object
+	Extra   []byte
+}
+
+func (*CreateSoup) Command() protocol.Command {
+	return protocol.CREATE_SOUP
+}
+
+func (m *CreateSoup) Marshal() ([]byte, error) {
+	if m.Indexes == nil {
+		return nil, errMissingObject
+	}
+	var w writer
+	w.object(NewString(m.Name))
+	w.object(m.Indexes)
+	return append(w.data, m.Extra...), nil
+}
+
+func (m *CreateSoup) Unmarshal(data []byte) error {
+	r := reader{data: data}
+	m.Name = StringValue(r.object())
+	m.Indexes = r.object()
+	m.Extra = r.rest()
+	return r.err
+}
+
+// CreateDefaultSoup creates a soup registered by an application on the
+// current store.
+type CreateDefaultSoup struct {
+	Name  string
+	Extra []byte
+}
+
+func (*CreateDefaultSoup) Command() protocol.Command {
+	return protocol.CREATE_DEFAULT_SOUP
+}
+
+func (m *CreateDefaultSoup) Marshal() ([]byte, error) {
+	return marshalObject(NewString(m.Name), m.Extra)
+}
+
+func (m *CreateDefaultSoup) Unmarshal(data []byte) error {
+	var name nsof.Object
+	err := unmarshalObject(data, &name, &m.Extra)
+	m.Name = StringValue(name)
+	return err
+}
+
+// SoupNotDirty tells the desktop that the current soup has not changed
+// since the last sync.
+type SoupNotDirty struct{ empty }
+
+func (*SoupNotDirty) Command() protocol.Command {
+	return protocol.SOUP_NOT_DIRTY
+}
+
+// GetChangedIndex asks for the indexes of the current soup changed since
+// the last sync.
+type GetChangedIndex struct{ empty }
+
+func (*GetChangedIndex) Command() protocol.Command {
+	return protocol.GET_CHANGED_INDEX
+}
+
+// GetChangedInfo asks for the info frame of the current soup if it
+// changed since the last sync.
+type GetChangedInfo struct{ empty }
+
+func (*GetChangedInfo) Command() protocol.Command {
+	return protocol.GET_CHANGED_INFO
+}
+
+// GetInheritance asks for the class hierarchy of the Newton.
+type GetInheritance struct{ empty }
+
+func (*GetInheritance) Command() protocol.Command {
+	return protocol.GET_INHERITANCE
+}
+
+// Inheritance is the answer to GetInheritance.
+type Inheritance struct {
+	Classes nsof.Object
+	Extra   []byte
+}
+
+func (*Inheritance) Command() protocol.Command {
+	return protocol.INHERITANCE
+}
+
+func (m *Inheritance) Marshal() ([]byte, error) {
+	return marshalObject(m.Classes, m.Extra)
+}
+
+func (m *Inheritance) Unmarshal(data []byte) error {
+	return unmarshalObject(data, &m.Classes, &m.Extra)
+}
@@ -0,0 +1,382 @@
+package messages
+
+import (
+	"gdcl/v3/nsof"
+	"gdcl/v3/protocol"
+)
+
+func init() {
+	register(func() Message { return &Synchronize{} })
+	register(func() Message { return &GetSyncOptions{} })
+	register(func() Message { return &SyncOptions{} })
+	register(func() Message { return &SyncResults{} })
+	register(func() Message { return &SoupsChanged{} })
+	register(func() Message { return &SourceVersion{} })
+	register(func() Message { return &BackupSoup{} })
+	register(func() Message { return &SendSoup{} })
+	register(func() Message { return &BackupIDs{} })
+	register(func() Message { return &BackupSoupDone{} })
+	register(func() Message { return &AddEntryWithUniqueID{} })
+	register(func() Message { return &ReturnChangedEntry{} })
+	register(func() Message { return &ChangedEntry{} })
+	register(func() Message { return &GetIndexDescription{} })
+	register(func() Message { return &IndexDescription{} })
+	register(func() Message { return &SetStoreSignature{} })
+	register(func() Message { return &SetSoupSignature{} })
+	register(func() Message { return &SetStoreName{} })
+	register(func() Message { return &SetVBOCompression{} })
+	register(func() Message { return &GetPassword{} })
+	register(func() Message { return &RefTest{} })
+	register(func() Message { return &Test{} })
+}
+
+// Synchronize is sent by the Newton when the user starts a sync there.
+type Synchronize struct{ empty }
+
+func (*Synchronize) Command() protocol.Command {
+	return protocol.SYNCHRONIZE
+}
+
+// GetSyncOptions asks for the sync options the user picked on the Newton.
+type GetSyncOptions struct{ empty }
+
+func (*GetSyncOptions) Command() protocol.Command {
+	return protocol.GET_SYNC_OPTIONS
+}
+
+// SyncOptions is the answer to GetSyncOptions, a frame with the stores,
+// packages and applications to sync.
+type SyncOptions struct {
+	Options nsof.Object
+	Extra   []byte
+}
+
+func (*SyncOptions) Command() protocol.Command {
+	return protocol.SYNC_OPTIONS
+}
+
+func (m *SyncOptions) Marshal() ([]byte, error) {
+	return marshalObject(m.Options, m.Extra)
+}
+
+func (m *SyncOptions) Unmarshal(data []byte) error {
+	return unmarshalObject(data, &m.Options, &m.Extra)
+}
+
+// SyncResults reports what a sync changed, for the Newton to show.
+type SyncResults struct {
+	Results nsof.Object
+	Extra   []byte
+}
+
+func (*SyncResults) Command() protocol.Command {
+	return protocol.SYNC_RESULTS
+}
+
+func (m *SyncResults) Marshal() ([]byte, error) {
+	return marshalObject(m.Results, m.Extra)
+}
+
+func (m *SyncResults) Unmarshal(data []byte) error {
+	return unmarshalObject(data, &m.Results, &m.Extra)
+}
+
+// SoupsChanged lists the soups a sync changed.
+type SoupsChanged struct {
+	Soups nsof.Object
+	Extra []byte
+}
+
+func (*SoupsChanged) Command() protocol.Command {
+	return protocol.SOUPS_CHANGED
+}
+
+func (m *SoupsChanged) Marshal() ([]byte, error) {
+	return marshalObject(m.Soups, m.Extra)
+}
+
+func (m *SoupsChanged) Unmarshal(data []byte) error {
+	return unmarshalObject(data, &m.Soups, &m.Extra)
+}
+
+// SourceVersion tells the Newton the system version the following
+// entries come from, so it can convert them.
+type SourceVersion struct {
+	Version uint32
+	Extra   []byte
+}
+
+func (*SourceVersion) Command() protocol.Command {
+	return protocol.SOURCE_VERSION
+}
+
+func (m *SourceVersion) Marshal() ([]byte, error) {
+	return marshalLong(m.Version, m.Extra)
+}
+
+func (m *SourceVersion) Unmarshal(data []byte) error {
+	return unmarshalLong(data, &m.Version, &m.Extra)
+}
+
+// BackupSoup asks for the entries of the current soup changed since
+// LastBackupTime, in minutes since 1904. The Newton answers with
+// BackupIDs, the entries and BackupSoupDone.
+type BackupSoup struct {
+	LastBackupTime uint32
+	Extra          []byte
+}
+
+func (*BackupSoup) Command() protocol.Command {
+	return protocol.BACKUP_SOUP
+}
+
+func (m *BackupSoup) Marshal() ([]byte, error) {
+	return marshalLong(m.LastBackupTime, m.Extra)
+}
+
+func (m *BackupSoup) Unmarshal(data []byte) error {
+	return unmarshalLong(data, &m.LastBackupTime, &m.Extra)
+}
+
+// SendSoup asks for all entries of the current soup, followed by
+// BackupSoupDone.
+type SendSoup struct{ empty }
+
+func (*SendSoup) Command() protocol.Command {
+	return protocol.SEND_SOUP
+}
+
+// BackupIDs lists the entries of the soup being backed up.
+type BackupIDs struct {
+	IDs   []uint32
+	Extra []byte
+}
+
+func (*BackupIDs) Command() protocol.Command {
+	return protocol.BACKUP_IDS
+}
+
+func (m *BackupIDs) Marshal() ([]byte, error) {
+	return marshalIDs(m.IDs, m.Extra)
+}
+
+func (m *BackupIDs) Unmarshal(data []byte) error {
+	return unmarshalIDs(data, &m.IDs, &m.Extra)
+}
+
+// BackupSoupDone ends the entries sent for BackupSoup or SendSoup.
+type BackupSoupDone struct{ empty }
+
+func (*BackupSoupDone) Command() protocol.Command {
+	return protocol.BACKUP_SOUP_DONE
+}
+
+// AddEntryWithUniqueID adds Entry to the current soup, giving it a new
+// ID if its _uniqueID is taken.
+type AddEntryWithUniqueID struct {
+	Entry nsof.Object
+	Extra []byte
+}
+
+func (*AddEntryWithUniqueID) Command() protocol.Command {
+	return protocol.ADD_ENTRY_WITH_UNIQUE_ID
+}
+
+func (m *AddEntryWithUniqueID) Marshal() ([]byte, error) {
+	return marshalObject(m.Entry, m.Extra)
+}
+
+func (m *AddEntryWithUniqueID) Unmarshal(data []byte) error {
+	return unmarshalObject(data, &m.Entry, &m.Extra)
+}
+
+// ReturnChangedEntry asks for an entry of the current soup by ID. The
+// Newton answers with ChangedEntry.
+type ReturnChangedEntry struct {
+	ID    uint32
+	Extra []byte
+}
+
+func (*ReturnChangedEntry) Command() protocol.Command {
+	return protocol.RETURN_CHANGED_ENTRY
+}
+
+func (m *ReturnChangedEntry) Marshal() ([]byte, error) {
+	return marshalLong(m.ID, m.Extra)
+}
+
+func (m *ReturnChangedEntry) Unmarshal(data []byte) error {
+	return unmarshalLong(data, &m.ID, &m.Extra)
+}
+
+// ChangedEntry is the answer to ReturnChangedEntry.
+type ChangedEntry struct {
+	Entry nsof.Object
+	Extra []byte
+}
+
+func (*ChangedEntry) Command() protocol.Command {
+	return protocol.CHANGED_ENTRY
+}
+
+func (m *ChangedEntry) Marshal() ([]byte, error) {
+	return marshalObject(m.Entry, m.Extra)
+}
+
+func (m *ChangedEntry) Unmarshal(data []byte) error {
+	return unmarshalObject(data, &m.Entry, &m.Extra)
+}
+
+// GetIndexDescription asks for the indexes of the current soup.
+type GetIndexDescription struct{ empty }
+
+func (*GetIndexDescription) Command() protocol.Command {
+	return protocol.GET_INDEX_DESCRIPTION
+}
+
+// IndexDescription is the answer to GetIndexDescription, an array of index
+// specs.
+type IndexDescription struct {
+	Indexes nsof.Object
+	Extra   []byte
+}
+
+func (*IndexDescription) Command() protocol.Command {
+	return protocol.INDEX_DESCRIPTION
+}
+
+func (m *IndexDescription) Marshal() ([]byte, error) {
+	return marshalObject(m.Indexes, m.Extra)
+}
+
+func (m *IndexDescription) Unmarshal(data []byte) error {
+	return unmarshalObject(data, &m.Indexes, &m.Extra)
+}
+
+// SetStoreSignature sets the signature of the current store.
+type SetStoreSignature struct {
+	Signature uint32
+	Extra     []byte
+}
+
+func (*SetStoreSignature) Command() protocol.Command {
+	return protocol.SET_STORE_SIGNATURE
+}
+
+func (m *SetStoreSignature) Marshal() ([]byte, error) {
+	return marshalLong(m.Signature, m.Extra)
+}
+
+func (m *SetStoreSignature) Unmarshal(data []byte) error {
+	return unmarshalLong(data, &m.Signature, &m.Extra)
+}
+
+// SetSoupSignature sets the signature of the current soup.
+type SetSoupSignature struct {
+	Signature uint32
+	Extra     []byte
+}
+
+func (*SetSoupSignature) Command() protocol.Command {
+	return protocol.SET_SOUP_SIGNATURE
+}
+
+func (m *SetSoupSignature) Marshal() ([]byte, error) {
+	return marshalLong(m.Signature, m.Extra)
+}
+
+func (m *SetSoupSignature) Unmarshal(data []byte) error {
+	return unmarshalLong(data, &m.Signature, &m.Extra)
+}
+
+// SetStoreName renames the current store.
+type SetStoreName struct {
+	Name  string
+	Extra []byte
+}
+
+func (*SetStoreName) Command() protocol.Command {
+	return protocol.SET_STORE_NAME
+}
+
+func (m *SetStoreName) Marshal() ([]byte, error) {
+	return marshalString(m.Name, m.Extra)
+}
+
+func (m *SetStoreName) Unmarshal(data []byte) error {
+	return unmarshalString(data, &m.Name, &m.Extra)
+}
+
+// SetVBOCompression selects whether virtual binary objects sent from now
+// on are compressed, 1, or not, 0.
+type SetVBOCompression struct {
+	Compression uint32
+	Extra       []byte
+}
+
+func (*SetVBOCompression) Command() protocol.Command {
+	return protocol.SET_VBO_COMPRESSION
+}
+
+func (m *SetVBOCompression) Marshal() ([]byte, error) {
+	return marshalLong(m.Compression, m.Extra)
+}
+
+func (m *SetVBOCompression) Unmarshal(data []byte) error {
+	return unmarshalLong(data, &m.Compression, &m.Extra)
+}
+
+// GetPassword asks the user on the Newton for a password, showing Prompt.
+// The Newton answers with Password.
+type GetPassword struct {
+	Prompt string
+	Extra  []byte
+}
+
+func (*GetPassword) Command() protocol.Command {
+	return protocol.GET_PASSWORD
+}
+
+func (m *GetPassword) Marshal() ([]byte, error) {
+	return marshalString(m.Prompt, m.Extra)
+}
+
+func (m *GetPassword) Unmarshal(data []byte) error {
+	return unmarshalString(data, &m.Prompt, &m.Extra)
+}
+
+// RefTest sends an object for the Newton to send back unchanged.
+type RefTest struct {
+	Object nsof.Object
+	Extra  []byte
+}
+
+func (*RefTest) Command() protocol.Command {
+	return protocol.REF_TEST
+}
+
+func (m *RefTest) Marshal() ([]byte, error) {
+	return marshalObject(m.Object, m.Extra)
+}
+
+func (m *RefTest) Unmarshal(data []byte) error {
+	return unmarshalObject(data, &m.Object, &m.Extra)
+}
+
+// Test carries arbitrary data for testing the connection.
+type Test struct {
+	Data []byte
+}
+
+func (*Test) Command() protocol.Command {
+	return protocol.TEST
+}
+
+func (m *Test) Marshal() ([]byte, error) {
+	return m.Data, nil
+}
+
+func (m *Test) Unmarshal(data []byte) error {
+	m.Data = data
+	return nil
+}
@@ -0,0 +1,119 @@
+package mnp
+
+import (
+	"gdcl/v3/protocol"
+	"log"
+)
+
+// Attention types in LN packets
+const (
+	attentionDestructive byte = 1
+	attentionExpedited   byte = 2
+)
+
+// attention is the state of the link attention exchange. Only one LN may be
+// unacknowledged at a time; a further attention is dropped until the LNA for
+// the previous one has arrived.
+type attention struct {
+	localAttentionSequenceNumber byte
+	peerAttentionSequenceNumber  byte
+	attentionOutstanding         []byte
+	attentionTimer               *protocol.Timer
+	attentionRetransmits         int
+}
+
+func (layer *Layer) resetAttention() {
+	layer.stopAttentionTimer()
+	layer.localAttentionSequenceNumber = 0
+	layer.peerAttentionSequenceNumber = 0
+	layer.attentionOutstanding = nil
+}
+
+func (layer *Layer) stopAttentionTimer() {
+	if layer.attentionTimer != nil {
+		layer.attentionTimer.Stop()
+		layer.attentionTimer = nil
+	}
+}
+
+func (layer *Layer) sendAttention(destructive bool) {
+	if layer.machine.State != dataPhase && layer.machine.State != disconnecting {
+		return
+	}
+	if layer.attentionOutstanding != nil {
+		log.Println("Attention already outstanding, dropping attention")
+		return
+	}
+	attentionType := attentionExpedited
+	if destructive {
+		attentionType = attentionDestructive
+		layer.discardUnsentPackets()
+	}
+	layer.localAttentionSequenceNumber++
+	layer.attentionOutstanding = []byte{7, ln,
+		paramSequenceNumber, 1, layer.localAttentionSequenceNumber,
+		paramAttentionType, 1, attentionType}
+	layer.attentionRetransmits = 0
+	layer.transmitAttention()
+}
+
+func (layer *Layer) transmitAttention() {
+	layer.events <- &protocol.MnpEvent{
+		Direction: protocol.Out,
+		Data:      layer.attentionOutstanding,
+	}
+	layer.attentionTimer = protocol.StartTimer(layer.events, retransmitTimeout)
+}
+
+func (layer *Layer) retransmitAttention() {
+	if layer.attentionRetransmits >= maxRetransmits {
+		log.Printf("LN %d not acknowledged, disconnecting", layer.localAttentionSequenceNumber)
+		layer.sendLinkDisconnect(reasonRetransmitLimit)
+		return
+	}
+	layer.attentionRetransmits++
+	layer.transmitAttention()
+}
+
+func (layer *Layer) acknowledgeAttention(sequenceNumber byte) {
+	if layer.attentionOutstanding == nil || sequenceNumber != layer.localAttentionSequenceNumber {
+		log.Printf("Ignoring LNA %d", sequenceNumber)
+		return
+	}
+	layer.stopAttentionTimer()
+	layer.attentionOutstanding = nil
+}
+
+// receiveAttention acknowledges an LN and passes it on unless it is a
+// retransmission of the previous one.
+func (layer *Layer) receiveAttention(sequenceNumber byte, attentionType byte) {
+	layer.events <- &protocol.MnpEvent{
+		Direction: protocol.Out,
+		Data:      []byte{4, lna, paramSequenceNumber, 1, sequenceNumber},
+	}
+	if sequenceNumber == layer.peerAttentionSequenceNumber {
+		return
+	}
+	layer.peerAttentionSequenceNumber = sequenceNumber
+	destructive := attentionType == attentionDestructive
+	if destructive {
+		layer.dockPacketStarted = false
+		layer.dockPacket = nil
+	}
+	layer.events <- &protocol.AttentionEvent{
+		Direction:   protocol.In,
+		Destructive: destructive,
+	}
+}
+
+// discardUnsentPackets drops the queued LT packets which have not been sent
+// yet, a destructive attention cancels the data they carry.
+func (layer *Layer) discardUnsentPackets() {
+	for i, packet := range layer.outstandingPackets {
+		if !packet.sent {
+			layer.localSendSequenceNumber -= byte(len(layer.outstandingPackets) - i)
+			layer.outstandingPackets = layer.outstandingPackets[:i]
+			return
+		}
+	}
+}
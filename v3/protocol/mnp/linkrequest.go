@@ -0,0 +1,98 @@
+package mnp
+
+import (
+	"errors"
+	"fmt"
+)
+
+// LR parameter types
+const (
+	paramConstant       byte = 1
+	paramFramingMode    byte = 2
+	paramMaxOutstanding byte = 3
+	paramMaxInfoLength  byte = 4
+	paramDataPhaseOpt   byte = 8
+)
+
+// Data phase optimization flags
+const (
+	optMaxInfo256  byte = 1
+	optFixedFields byte = 2
+)
+
+// linkParameters are the link parameters negotiated with the LR packets.
+type linkParameters struct {
+	framingMode    byte
+	maxOutstanding byte
+	maxInfoLength  uint16
+	dataPhaseOpt   byte
+}
+
+var (
+	errShortLinkRequest = errors.New("LR packet too short")
+	// A window or information field of zero would stall the link.
+	errNoWindow     = errors.New("LR max outstanding is zero")
+	errNoInfoLength = errors.New("LR max info length is zero")
+)
+
+// parseLinkRequest walks the type/length/value parameters of an LR packet.
+// Parameters which are not present keep their MNP defaults, unknown ones are
+// skipped.
+func parseLinkRequest(data []byte) (linkParameters, error) {
+	params := linkParameters{
+		framingMode:    2,
+		maxOutstanding: 1,
+		maxInfoLength:  64,
+	}
+	if len(data) < 3 || len(data) < int(data[0])+1 {
+		return params, errShortLinkRequest
+	}
+	err := walkParameters(data[3:int(data[0])+1], func(paramType byte, value []byte) error {
+		if len(value) == 0 {
+			return nil
+		}
+		switch paramType {
+		case paramFramingMode:
+			params.framingMode = value[0]
+		case paramMaxOutstanding:
+			params.maxOutstanding = value[0]
+		case paramMaxInfoLength:
+			if len(value) < 2 {
+				return fmt.Errorf("LR max info length has %d bytes", len(value))
+			}
+			params.maxInfoLength = uint16(value[0])<<8 | uint16(value[1])
+		case paramDataPhaseOpt:
+			params.dataPhaseOpt = value[0]
+		}
+		return nil
+	})
+	switch {
+	case err != nil:
+	case params.maxOutstanding == 0:
+		err = errNoWindow
+	case params.infoLength() == 0:
+		err = errNoInfoLength
+	}
+	return params, err
+}
+
+// infoLength is the maximum number of bytes in the information field of an
+// LT packet.
+func (params linkParameters) infoLength() int {
+	if params.dataPhaseOpt&optMaxInfo256 != 0 {
+		return 256
+	}
+	return int(params.maxInfoLength)
+}
+
+// encode builds the LR packet sent in response to the Newton's LR.
+func (params linkParameters) encode() []byte {
+	buf := []byte{0, lr, 2,
+		paramConstant, 6, 1, 0, 0, 0, 0, 255,
+		paramFramingMode, 1, params.framingMode,
+		paramMaxOutstanding, 1, params.maxOutstanding,
+		paramMaxInfoLength, 2, byte(params.maxInfoLength >> 8), byte(params.maxInfoLength),
+		paramDataPhaseOpt, 1, params.dataPhaseOpt}
+	buf[0] = byte(len(buf) - 1)
+	return buf
+}
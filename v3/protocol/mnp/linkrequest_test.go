@@ -0,0 +1,73 @@
+package mnp
+
+import "testing"
+
+func TestParseLinkRequest(t *testing.T) {
+	tests := []struct {
+		name           string
+		data           []byte
+		maxOutstanding byte
+		infoLength     int
+		err            error
+	}{
+		{
+			name: "Newton",
+			data: []byte{23, lr, 2, paramConstant, 6, 1, 0, 0, 0, 0, 255, paramFramingMode, 1, 2,
+				paramMaxOutstanding, 1, 8, paramMaxInfoLength, 2, 0, 64, paramDataPhaseOpt, 1, 3},
+			maxOutstanding: 8,
+			infoLength:     256,
+		},
+		{
+			name:           "defaults",
+			data:           []byte{2, lr, 2},
+			maxOutstanding: 1,
+			infoLength:     64,
+		},
+		{
+			name:           "unknown parameter",
+			data:           []byte{6, lr, 2, 99, 2, 1, 2},
+			maxOutstanding: 1,
+			infoLength:     64,
+		},
+		{
+			name:           "zero info length with 256 byte option",
+			data:           []byte{9, lr, 2, paramMaxInfoLength, 2, 0, 0, paramDataPhaseOpt, 1, 1},
+			maxOutstanding: 1,
+			infoLength:     256,
+		},
+		{
+			name: "zero info length",
+			data: []byte{9, lr, 2, paramMaxInfoLength, 2, 0, 0, paramDataPhaseOpt, 1, 0},
+			err:  errNoInfoLength,
+		},
+		{
+			name: "zero window",
+			data: []byte{5, lr, 2, paramMaxOutstanding, 1, 0},
+			err:  errNoWindow,
+		},
+		{
+			name: "short",
+			data: []byte{9, lr, 2},
+			err:  errShortLinkRequest,
+		},
+		{
+			name: "truncated parameter",
+			data: []byte{4, lr, 2, paramMaxOutstanding, 1},
+			err:  errShortParameter,
+		},
+	}
+	for _, test := range tests {
+		params, err := parseLinkRequest(test.data)
+		if err != test.err {
+			t.Errorf("%s: error %v, want %v", test.name, err, test.err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if params.maxOutstanding != test.maxOutstanding || params.infoLength() != test.infoLength {
+			t.Errorf("%s: window %d, info length %d, want %d, %d", test.name,
+				params.maxOutstanding, params.infoLength(), test.maxOutstanding, test.infoLength)
+		}
+	}
+}
@@ -3,8 +3,11 @@ package mnp
 import (
 	"bytes"
 	"encoding/binary"
+	"fmt"
 	"gdcl/v3/fsm"
 	"gdcl/v3/protocol"
+	"log"
+	"time"
 )
 
 const (
@@ -15,10 +18,12 @@ const (
 )
 
 const (
-	lr byte = 1
-	ld byte = 2
-	lt byte = 4
-	la byte = 5
+	lr  byte = 1
+	ld  byte = 2
+	lt  byte = 4
+	la  byte = 5
+	ln  byte = 6
+	lna byte = 7
 )
 
 const (
@@ -27,6 +32,24 @@ const (
 	handleLinkAcknowledgement
 	closeConnection
 	handleLinkTransfer
+	handleLinkAttention
+	handleLinkAttentionAcknowledgement
+)
+
+// LD reason codes
+const (
+	reasonProtocolError      byte = 1
+	reasonUnexpectedConstant byte = 2
+	reasonIncompatibleParams byte = 3
+	reasonRetransmitLimit    byte = 4
+	reasonInactivityTimeout  byte = 5
+	reasonUserInitiated      byte = 255
+)
+
+const (
+	retransmitTimeout = 2 * time.Second
+	maxRetransmits    = 8
+	disconnectTimeout = 5 * time.Second
 )
 
 type packetType struct {
@@ -36,22 +59,35 @@ type packetType struct {
 type outstandingPacket struct {
 	data               []byte
 	sendSequenceNumber byte
+	sent               bool
+	timer              *protocol.Timer
+	retransmits        int
+	// progress is reported once the packet has been acknowledged.
+	progress protocol.ProgressEvent
 }
 
-var (
-	state                     int = idle
-	maxInfoLength             int
-	lastAckSequenceNumber     byte
-	outstandingPackets        []outstandingPacket
-	maxOutstanding            byte
-	sendCredits               byte
-	receiveCredits            byte
-	localSendSequenceNumber   byte
-	peerSendSequenceNumber    byte
-	peerReceiveSequenceNumber byte
-	dockPacket                protocol.DockEvent
-	dockPacketStarted         bool
-)
+// Layer is the MNP link of one session. It turns incoming MNP packets into
+// dock packets and sends outgoing dock packets as LTs within the window
+// negotiated with the Newton.
+type Layer struct {
+	events                  chan<- protocol.Event
+	machine                 fsm.Machine[int, byte, int]
+	maxInfoLength           int
+	lastAckSequenceNumber   byte
+	outstandingPackets      []*outstandingPacket
+	maxOutstanding          byte
+	sendCredits             byte
+	localSendSequenceNumber byte
+	peerSendSequenceNumber  byte
+	dockPacket              *protocol.DockEvent
+	dockPacketStarted       bool
+	disconnectTimer         *protocol.Timer
+	attention
+}
+
+func New(events chan<- protocol.Event) *Layer {
+	return &Layer{events: events, machine: fsm.Machine[int, byte, int]{State: idle, Table: Table}}
+}
 
 var transitions = []fsm.Transition[int, byte, int]{
 	{State: idle, Event: lr, NewState: linkRequest, Action: sendLinkRequestResponse},
@@ -63,118 +99,331 @@ var transitions = []fsm.Transition[int, byte, int]{
 	{State: dataPhase, Event: la, NewState: dataPhase, Action: handleLinkAcknowledgement},
 	{State: dataPhase, Event: ld, NewState: idle, Action: closeConnection},
 	{State: dataPhase, Event: lt, NewState: dataPhase, Action: handleLinkTransfer},
+	{State: dataPhase, Event: ln, NewState: dataPhase, Action: handleLinkAttention},
+	{State: dataPhase, Event: lna, NewState: dataPhase, Action: handleLinkAttentionAcknowledgement},
+	{State: disconnecting, Event: lr, NewState: idle},
+	{State: disconnecting, Event: la, NewState: disconnecting, Action: handleLinkAcknowledgement},
+	{State: disconnecting, Event: ld, NewState: idle, Action: closeConnection},
+	{State: disconnecting, Event: lt, NewState: disconnecting, Action: handleLinkTransfer},
+	{State: disconnecting, Event: ln, NewState: disconnecting, Action: handleLinkAttention},
+	{State: disconnecting, Event: lna, NewState: disconnecting, Action: handleLinkAttentionAcknowledgement},
 }
 
-var ReducedWindow = true
+// Table describes the MNP link states. disconnecting is entered by
+// disconnect rather than by a packet.
+var Table = &fsm.Table[int, byte, int]{
+	Name:        "mnp",
+	Transitions: transitions,
+	Initial:     []int{idle, disconnecting},
+	States: map[int]string{
+		idle:          "idle",
+		linkRequest:   "linkRequest",
+		dataPhase:     "dataPhase",
+		disconnecting: "disconnecting",
+	},
+	Events: map[byte]string{
+		lr:  "lr",
+		ld:  "ld",
+		lt:  "lt",
+		la:  "la",
+		ln:  "ln",
+		lna: "lna",
+	},
+	Actions: map[int]string{
+		sendLinkRequestResponse:            "sendLinkRequestResponse",
+		handleLinkAcknowledgement:          "handleLinkAcknowledgement",
+		closeConnection:                    "closeConnection",
+		handleLinkTransfer:                 "handleLinkTransfer",
+		handleLinkAttention:                "handleLinkAttention",
+		handleLinkAttentionAcknowledgement: "handleLinkAttentionAcknowledgement",
+	},
+}
+
+func init() {
+	Table.MustValidate()
+}
 
-func processIn(event *protocol.MnpEvent) {
+func (layer *Layer) processIn(event *protocol.MnpEvent) {
 	var action int
-	var packetType = event.Data[1]
-	action, state = fsm.Input(packetType, state, transitions)
+	packet, err := parsePacket(event.Data)
+	if err != nil {
+		layer.events <- &protocol.ErrorEvent{Err: &protocol.MnpError{Err: err}}
+		return
+	}
+	action, err = layer.machine.Input(packet.packetType, nil)
+	if err != nil {
+		layer.events <- &protocol.ErrorEvent{Err: &protocol.MnpError{Err: err}}
+		return
+	}
 	switch action {
 	case sendLinkRequestResponse:
-		framingMode := event.Data[13]
-		if ReducedWindow {
-			maxOutstanding = 1
-		} else {
-			maxOutstanding = event.Data[16]
+		params, err := parseLinkRequest(event.Data)
+		if err != nil {
+			layer.events <- &protocol.ErrorEvent{Err: &protocol.MnpError{Err: err}}
+			layer.machine.Goto(idle)
+			break
 		}
-		dataPhaseOpt := event.Data[23]
-		if dataPhaseOpt&0x1 == 0x1 {
-			maxInfoLength = 256
-		} else {
-			maxInfoLength = int(event.Data[19])*256 + int(event.Data[20])
-		}
-		buf := []byte{23, lr, 2,
-			1, 6, 1, 0, 0, 0, 0, 255,
-			2, 1, framingMode,
-			3, 1, maxOutstanding,
-			4, 2, event.Data[19], event.Data[20],
-			8, 1, dataPhaseOpt}
-		outstandingPackets = make([]outstandingPacket, 0, maxOutstanding)
-		protocol.Events <- &protocol.MnpEvent{
+		layer.maxOutstanding = params.maxOutstanding
+		layer.maxInfoLength = params.infoLength()
+		layer.outstandingPackets = make([]*outstandingPacket, 0, layer.maxOutstanding)
+		layer.localSendSequenceNumber = 0
+		layer.lastAckSequenceNumber = 0
+		layer.peerSendSequenceNumber = 0
+		layer.resetAttention()
+		layer.events <- &protocol.MnpEvent{
 			Direction: protocol.Out,
-			Data:      buf,
+			Data:      params.encode(),
 		}
-		receiveCredits = maxOutstanding
+		layer.sendCredits = layer.maxOutstanding
 	case handleLinkAcknowledgement:
-		peerReceiveSequenceNumber = event.Data[2]
-		receiveCredits = event.Data[3]
-		if receiveCredits > maxOutstanding {
-			receiveCredits = maxOutstanding
+		layer.acknowledge(packet.sequenceNumber, packet.credits)
+	case handleLinkTransfer:
+		if packet.sequenceNumber != layer.peerSendSequenceNumber+1 {
+			log.Printf("Ignoring LT %d, expected %d", packet.sequenceNumber, layer.peerSendSequenceNumber+1)
+			layer.sendAcknowledgement()
+			break
 		}
-		lastAckSequenceNumber = peerReceiveSequenceNumber
-		for i := 0; i < len(outstandingPackets) && receiveCredits > 0; i++ {
-			packet := outstandingPackets[i]
-			if packet.sendSequenceNumber <= peerReceiveSequenceNumber {
-				continue
-			}
-			protocol.Events <- &protocol.MnpEvent{
-				Direction: protocol.Out,
-				Data:      packet.data,
+		layer.peerSendSequenceNumber = packet.sequenceNumber
+		layer.sendAcknowledgement()
+		layer.receiveDockData(packet.info)
+	case handleLinkAttention:
+		layer.receiveAttention(packet.sequenceNumber, packet.attentionType)
+	case handleLinkAttentionAcknowledgement:
+		layer.acknowledgeAttention(packet.sequenceNumber)
+	case closeConnection:
+		layer.stopTimers()
+		layer.events <- &protocol.QuitEvent{}
+	}
+}
+
+// receiveDockData adds the information field of an LT to the dock packet
+// being received and passes the packet on once it is complete.
+func (layer *Layer) receiveDockData(info []byte) {
+	if !layer.dockPacketStarted {
+		if len(info) < 16 {
+			layer.events <- &protocol.ErrorEvent{
+				Err: &protocol.DockError{Err: fmt.Errorf("dock packet header too short: %d bytes", len(info))},
 			}
-			receiveCredits--
+			return
 		}
-	case handleLinkTransfer:
-		peerSendSequenceNumber = event.Data[2]
-		protocol.Events <- &protocol.MnpEvent{
-			Direction: protocol.Out,
-			Data:      []byte{3, la, peerSendSequenceNumber, 8},
+		layer.dockPacket = &protocol.DockEvent{
+			Direction: protocol.In,
+			Command:   protocol.Command(binary.BigEndian.Uint32(info[8:12])),
+			Length:    binary.BigEndian.Uint32(info[12:16]),
+			Data:      append([]byte{}, info[16:]...),
+		}
+		layer.dockPacketStarted = true
+	} else {
+		layer.dockPacket.Data = append(layer.dockPacket.Data, info...)
+	}
+	if uint32(len(layer.dockPacket.Data)) >= layer.dockPacket.Length {
+		layer.dockPacketStarted = false
+		layer.dockPacket.Data = layer.dockPacket.Data[:layer.dockPacket.Length]
+		layer.events <- layer.dockPacket
+	}
+}
+
+func (layer *Layer) sendAcknowledgement() {
+	layer.events <- &protocol.MnpEvent{
+		Direction: protocol.Out,
+		Data:      []byte{3, la, layer.peerSendSequenceNumber, layer.maxOutstanding},
+	}
+}
+
+// acknowledge removes the packets up to and including sequenceNumber from the
+// send window and sends further packets as far as the credits allow. Sequence
+// numbers wrap at 256, so the number of acknowledged packets is the distance
+// from the previous acknowledgement.
+func (layer *Layer) acknowledge(sequenceNumber byte, credits byte) {
+	acknowledged := int(sequenceNumber - layer.lastAckSequenceNumber)
+	sent := 0
+	for sent < len(layer.outstandingPackets) && layer.outstandingPackets[sent].sent {
+		sent++
+	}
+	if acknowledged > sent {
+		log.Printf("Ignoring LA %d, last acknowledged %d", sequenceNumber, layer.lastAckSequenceNumber)
+		return
+	}
+	for _, packet := range layer.outstandingPackets[:acknowledged] {
+		packet.stopTimer()
+		progress := packet.progress
+		layer.events <- &progress
+	}
+	layer.outstandingPackets = layer.outstandingPackets[acknowledged:]
+	layer.lastAckSequenceNumber = sequenceNumber
+	layer.sendCredits = min(credits, layer.maxOutstanding)
+	layer.sendWindow()
+	if layer.machine.State == disconnecting && len(layer.outstandingPackets) == 0 {
+		layer.sendLinkDisconnect(reasonUserInitiated)
+	}
+}
+
+// sendWindow sends the queued packets which fit into the window granted by
+// the Newton.
+func (layer *Layer) sendWindow() {
+	for i, packet := range layer.outstandingPackets {
+		if i >= int(layer.sendCredits) {
+			break
 		}
-		if !dockPacketStarted {
-			buf := bytes.NewBuffer(event.Data[11:])
-			binary.Read(buf, binary.BigEndian, &dockPacket.Command)
-			binary.Read(buf, binary.BigEndian, &dockPacket.Length)
-			dockPacket.Data = event.Data[19:]
-			dockPacket.Direction = protocol.In
-			dockPacketStarted = true
-		} else {
-			dockPacket.Data = append(dockPacket.Data, event.Data[3:]...)
+		if !packet.sent {
+			layer.sendPacket(packet)
 		}
-		if uint32(len(dockPacket.Data)) >= dockPacket.Length {
-			dockPacketStarted = false
-			dockPacket.Data = dockPacket.Data[:dockPacket.Length]
-			protocol.Events <- &dockPacket
+	}
+}
+
+func (layer *Layer) sendPacket(packet *outstandingPacket) {
+	packet.stopTimer()
+	layer.events <- &protocol.MnpEvent{
+		Direction: protocol.Out,
+		Data:      packet.data,
+	}
+	packet.sent = true
+	packet.timer = protocol.StartTimer(layer.events, retransmitTimeout)
+}
+
+func (packet *outstandingPacket) stopTimer() {
+	if packet.timer != nil {
+		packet.timer.Stop()
+		packet.timer = nil
+	}
+}
+
+func (layer *Layer) stopTimers() {
+	for _, packet := range layer.outstandingPackets {
+		packet.stopTimer()
+	}
+	if layer.disconnectTimer != nil {
+		layer.disconnectTimer.Stop()
+		layer.disconnectTimer = nil
+	}
+	layer.stopAttentionTimer()
+}
+
+func (layer *Layer) sendLinkDisconnect(reason byte) {
+	layer.stopTimers()
+	layer.outstandingPackets = layer.outstandingPackets[:0]
+	layer.machine.Goto(idle)
+	layer.events <- &protocol.MnpEvent{
+		Direction: protocol.Out,
+		Data:      []byte{4, ld, 1, 1, reason},
+	}
+	layer.events <- &protocol.QuitEvent{}
+}
+
+// processFramingError acknowledges the last correctly received LT again so
+// the Newton retransmits the frames after it.
+func (layer *Layer) processFramingError() {
+	if layer.machine.State != dataPhase && layer.machine.State != disconnecting {
+		return
+	}
+	layer.sendAcknowledgement()
+}
+
+// startDisconnect waits for the outstanding packets to be acknowledged before
+// sending LD, but no longer than disconnectTimeout.
+func (layer *Layer) startDisconnect() {
+	layer.machine.Goto(disconnecting)
+	if layer.disconnectTimer != nil {
+		layer.disconnectTimer.Stop()
+	}
+	layer.disconnectTimer = protocol.StartTimer(layer.events, disconnectTimeout)
+}
+
+// processDisconnect handles a disconnect requested by the desktop. In the
+// data phase, the dock layer normally sends DISCONNECT first, which starts
+// the LD sequence; the timer covers the case that it does not.
+func (layer *Layer) processDisconnect() {
+	switch layer.machine.State {
+	case dataPhase:
+		if layer.disconnectTimer == nil {
+			layer.disconnectTimer = protocol.StartTimer(layer.events, disconnectTimeout)
 		}
-	case closeConnection:
-		protocol.Events <- protocol.NewDockEvent(protocol.APP_QUIT, protocol.In, []byte{})
+	case disconnecting:
+		layer.sendLinkDisconnect(reasonUserInitiated)
+	default:
+		layer.machine.Goto(idle)
+		layer.events <- &protocol.QuitEvent{}
+	}
+}
+
+func (layer *Layer) processTimer(event *protocol.TimerEvent) {
+	if event.Timer == layer.disconnectTimer {
+		layer.sendLinkDisconnect(reasonUserInitiated)
+		return
+	}
+	if event.Timer == layer.attentionTimer {
+		layer.retransmitAttention()
+		return
+	}
+	for _, packet := range layer.outstandingPackets {
+		if packet.timer != event.Timer {
+			continue
+		}
+		if packet.retransmits >= maxRetransmits {
+			log.Printf("LT %d not acknowledged, disconnecting", packet.sendSequenceNumber)
+			layer.sendLinkDisconnect(reasonRetransmitLimit)
+			return
+		}
+		packet.retransmits++
+		layer.sendPacket(packet)
+		return
 	}
 }
 
-func processOut(event *protocol.DockEvent) {
+func (layer *Layer) processOut(event *protocol.DockEvent) {
 	eventData := event.Encode()
+	total := len(eventData)
 	for len(eventData) > 0 {
-		localSendSequenceNumber++
+		layer.localSendSequenceNumber++
 		buf := new(bytes.Buffer)
 		binary.Write(buf, binary.BigEndian, byte(2))
 		binary.Write(buf, binary.BigEndian, byte(lt))
-		binary.Write(buf, binary.BigEndian, byte(localSendSequenceNumber))
+		binary.Write(buf, binary.BigEndian, byte(layer.localSendSequenceNumber))
 		n := len(eventData)
-		if n > int(maxInfoLength) {
-			n = int(maxInfoLength)
+		if n > int(layer.maxInfoLength) {
+			n = int(layer.maxInfoLength)
 		}
 		buf.Write(eventData[:n])
 		eventData = eventData[n:]
-		outstandingPackets = append(outstandingPackets, outstandingPacket{buf.Bytes(), localSendSequenceNumber})
-		if receiveCredits > 0 {
-			protocol.Events <- &protocol.MnpEvent{
-				Direction: protocol.Out,
-				Data:      buf.Bytes(),
-			}
-			receiveCredits--
-		}
+		layer.outstandingPackets = append(layer.outstandingPackets, &outstandingPacket{
+			data:               buf.Bytes(),
+			sendSequenceNumber: layer.localSendSequenceNumber,
+			progress: protocol.ProgressEvent{
+				Command: event.Command,
+				Done:    total - len(eventData),
+				Total:   total,
+			},
+		})
 	}
+	layer.sendWindow()
+	if event.Command == protocol.DISCONNECT {
+		layer.startDisconnect()
+	}
+}
+
+func (layer *Layer) SetTrace(trace func(step fsm.Step)) {
+	layer.machine.Trace = trace
 }
 
-func Process(event protocol.Event) {
+func (layer *Layer) Process(event protocol.Event) {
 	switch event.(type) {
 	case *protocol.MnpEvent:
 		if event.(*protocol.MnpEvent).Direction == protocol.In {
-			processIn(event.(*protocol.MnpEvent))
+			layer.processIn(event.(*protocol.MnpEvent))
+		}
+	case *protocol.FramingErrorEvent:
+		layer.processFramingError()
+	case *protocol.TimerEvent:
+		layer.processTimer(event.(*protocol.TimerEvent))
+	case *protocol.DisconnectEvent:
+		layer.processDisconnect()
+	case *protocol.AttentionEvent:
+		if event.(*protocol.AttentionEvent).Direction == protocol.Out {
+			layer.sendAttention(event.(*protocol.AttentionEvent).Destructive)
 		}
 	case *protocol.DockEvent:
 		if event.(*protocol.DockEvent).Direction == protocol.Out {
-			processOut(event.(*protocol.DockEvent))
+			layer.processOut(event.(*protocol.DockEvent))
 		}
 	}
 }
@@ -0,0 +1,142 @@
+package mnp
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Parameter types in LT, LA, LD, LN and LNA headers
+const (
+	paramSequenceNumber byte = 1
+	paramCredits        byte = 2
+	paramReason         byte = 1
+	paramAttentionType  byte = 2
+)
+
+// packet is an incoming MNP packet, decoded according to the header length
+// in its first byte. Both the fixed field and the optional parameter forms
+// of the LT, LA, LN and LNA headers are accepted.
+type packet struct {
+	packetType     byte
+	sequenceNumber byte
+	credits        byte
+	reason         byte
+	attentionType  byte
+	info           []byte
+}
+
+var errShortParameter = errors.New("MNP header parameter exceeds header length")
+
+// walkParameters calls f for each type/length/value parameter in header.
+func walkParameters(header []byte, f func(paramType byte, value []byte) error) error {
+	for len(header) > 0 {
+		if len(header) < 2 || len(header) < int(header[1])+2 {
+			return errShortParameter
+		}
+		paramType, value := header[0], header[2:int(header[1])+2]
+		header = header[int(header[1])+2:]
+		if err := f(paramType, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// byteParameter stores a single byte parameter value in dest.
+func byteParameter(dest *byte, found *bool, value []byte) error {
+	if len(value) != 1 {
+		return fmt.Errorf("MNP header parameter has %d bytes, expected 1", len(value))
+	}
+	*dest = value[0]
+	*found = true
+	return nil
+}
+
+func parsePacket(data []byte) (*packet, error) {
+	if len(data) < 2 {
+		return nil, fmt.Errorf("MNP packet too short: %d bytes", len(data))
+	}
+	headerLength := int(data[0])
+	if headerLength < 1 || len(data) < headerLength+1 {
+		return nil, fmt.Errorf("MNP header length %d, packet length %d", headerLength, len(data))
+	}
+	p := &packet{packetType: data[1], info: data[headerLength+1:]}
+	header := data[2 : headerLength+1]
+	var err error
+	var hasSequenceNumber, hasCredits, hasReason, hasAttentionType bool
+	switch p.packetType {
+	case lt:
+		if len(header) == 1 {
+			p.sequenceNumber, hasSequenceNumber = header[0], true
+			break
+		}
+		err = walkParameters(header, func(paramType byte, value []byte) error {
+			if paramType == paramSequenceNumber {
+				return byteParameter(&p.sequenceNumber, &hasSequenceNumber, value)
+			}
+			return nil
+		})
+		if err == nil && !hasSequenceNumber {
+			err = errors.New("LT without sequence number")
+		}
+	case la:
+		if len(header) == 2 {
+			p.sequenceNumber, p.credits = header[0], header[1]
+			break
+		}
+		err = walkParameters(header, func(paramType byte, value []byte) error {
+			switch paramType {
+			case paramSequenceNumber:
+				return byteParameter(&p.sequenceNumber, &hasSequenceNumber, value)
+			case paramCredits:
+				return byteParameter(&p.credits, &hasCredits, value)
+			}
+			return nil
+		})
+		if err == nil && !(hasSequenceNumber && hasCredits) {
+			err = errors.New("LA without sequence number or credits")
+		}
+	case ln:
+		if len(header) == 2 {
+			p.sequenceNumber, p.attentionType = header[0], header[1]
+			break
+		}
+		err = walkParameters(header, func(paramType byte, value []byte) error {
+			switch paramType {
+			case paramSequenceNumber:
+				return byteParameter(&p.sequenceNumber, &hasSequenceNumber, value)
+			case paramAttentionType:
+				return byteParameter(&p.attentionType, &hasAttentionType, value)
+			}
+			return nil
+		})
+		if err == nil && !(hasSequenceNumber && hasAttentionType) {
+			err = errors.New("LN without sequence number or attention type")
+		}
+	case lna:
+		if len(header) == 1 {
+			p.sequenceNumber = header[0]
+			break
+		}
+		err = walkParameters(header, func(paramType byte, value []byte) error {
+			if paramType == paramSequenceNumber {
+				return byteParameter(&p.sequenceNumber, &hasSequenceNumber, value)
+			}
+			return nil
+		})
+		if err == nil && !hasSequenceNumber {
+			err = errors.New("LNA without sequence number")
+		}
+	case ld:
+		err = walkParameters(header, func(paramType byte, value []byte) error {
+			if paramType == paramReason {
+				return byteParameter(&p.reason, &hasReason, value)
+			}
+			return nil
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
@@ -0,0 +1,61 @@
+package mnp
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestParsePacket(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want packet
+	}{
+		{"LT fixed", []byte{2, lt, 7, 'a', 'b'}, packet{packetType: lt, sequenceNumber: 7, info: []byte("ab")}},
+		{"LT parameters", []byte{4, lt, paramSequenceNumber, 1, 7, 'a'},
+			packet{packetType: lt, sequenceNumber: 7, info: []byte("a")}},
+		{"LA fixed", []byte{3, la, 5, 8}, packet{packetType: la, sequenceNumber: 5, credits: 8}},
+		{"LA parameters", []byte{7, la, paramSequenceNumber, 1, 5, paramCredits, 1, 8},
+			packet{packetType: la, sequenceNumber: 5, credits: 8}},
+		{"LN fixed", []byte{3, ln, 1, attentionDestructive},
+			packet{packetType: ln, sequenceNumber: 1, attentionType: attentionDestructive}},
+		{"LN parameters", []byte{7, ln, paramSequenceNumber, 1, 1, paramAttentionType, 1, attentionExpedited},
+			packet{packetType: ln, sequenceNumber: 1, attentionType: attentionExpedited}},
+		{"LNA fixed", []byte{2, lna, 3}, packet{packetType: lna, sequenceNumber: 3}},
+		{"LNA parameters", []byte{4, lna, paramSequenceNumber, 1, 3}, packet{packetType: lna, sequenceNumber: 3}},
+		{"LD", []byte{4, ld, paramReason, 1, reasonUserInitiated}, packet{packetType: ld, reason: reasonUserInitiated}},
+	}
+	for _, test := range tests {
+		p, err := parsePacket(test.data)
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+		want := test.want
+		if p.packetType != want.packetType || p.sequenceNumber != want.sequenceNumber ||
+			p.credits != want.credits || p.reason != want.reason ||
+			p.attentionType != want.attentionType || !bytes.Equal(p.info, want.info) {
+			t.Errorf("%s: got %+v, want %+v", test.name, *p, want)
+		}
+	}
+}
+
+func TestParsePacketErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", []byte{}},
+		{"header longer than packet", []byte{5, lt, 1}},
+		{"LT without sequence number", []byte{4, lt, paramCredits, 1, 1}},
+		{"LA without credits", []byte{4, la, paramSequenceNumber, 1, 1}},
+		{"LN without attention type", []byte{4, ln, paramSequenceNumber, 1, 1}},
+		{"parameter too long", []byte{4, lna, paramSequenceNumber, 2, 1}},
+		{"parameter of two bytes", []byte{5, lna, paramSequenceNumber, 2, 1, 2}},
+	}
+	for _, test := range tests {
+		if p, err := parsePacket(test.data); err == nil {
+			t.Errorf("%s: got %+v, want an error", test.name, *p)
+		}
+	}
+}
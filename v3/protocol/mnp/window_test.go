@@ -0,0 +1,48 @@
+package mnp
+
+import (
+	"gdcl/v3/protocol"
+	"testing"
+)
+
+// newDataPhaseLayer returns a layer in the data phase with sent packets
+// numbered from first.
+func newDataPhaseLayer(first byte, sent int) *Layer {
+	layer := New(make(chan protocol.Event, 100))
+	layer.machine.State = dataPhase
+	layer.maxOutstanding = 8
+	layer.sendCredits = 8
+	layer.lastAckSequenceNumber = first - 1
+	layer.localSendSequenceNumber = first - 1
+	for i := 0; i < sent; i++ {
+		layer.localSendSequenceNumber++
+		layer.outstandingPackets = append(layer.outstandingPackets, &outstandingPacket{
+			sendSequenceNumber: layer.localSendSequenceNumber,
+			sent:               true,
+		})
+	}
+	return layer
+}
+
+func TestAcknowledgeWraps(t *testing.T) {
+	tests := []struct {
+		name        string
+		first       byte
+		sent        int
+		ack         byte
+		outstanding int
+	}{
+		{"in order", 1, 3, 2, 1},
+		{"across 255", 254, 4, 0, 1},
+		{"all across 255", 254, 4, 1, 0},
+		{"none", 254, 4, 253, 4},
+		{"ahead of the sent packets", 254, 2, 1, 2},
+	}
+	for _, test := range tests {
+		layer := newDataPhaseLayer(test.first, test.sent)
+		layer.acknowledge(test.ack, 8)
+		if got := len(layer.outstandingPackets); got != test.outstanding {
+			t.Errorf("%s: %d packets outstanding, want %d", test.name, got, test.outstanding)
+		}
+	}
+}
@@ -1,9 +1,10 @@
 package info
 
 import (
+	"errors"
 	"gdcl/v3/fsm"
-	"gdcl/v3/nsof"
 	"gdcl/v3/protocol"
+	"gdcl/v3/protocol/messages"
 	"log"
 )
 
@@ -17,94 +18,161 @@ const (
 
 const (
 	noAction int = iota
-	getStoreNames
 	selectStore
-	getSoupNames
 	showSoupNames
 	showAppList
 	cancel
+	failed
 )
 
 var transitions = []fsm.Transition[int, protocol.Command, int]{
-	{State: idle, Event: protocol.APP_CONNECTED, Action: getStoreNames, NewState: gettingStoreNames},
-	{State: idle, Fallback: true, NewState: idle},
+	{State: idle, Fallback: true, KeepState: true},
 	{State: gettingStoreNames, Event: protocol.STORE_NAMES, Action: selectStore, NewState: selectingStore},
-	{State: gettingStoreNames, Event: protocol.OPERATION_CANCELED, Action: cancel, NewState: idle},
-	{State: gettingStoreNames, Fallback: true, NewState: gettingStoreNames},
-	{State: selectingStore, Event: protocol.RESULT, Action: getSoupNames, NewState: gettingSoupNames},
-	{State: selectingStore, Event: protocol.OPERATION_CANCELED, Action: cancel, NewState: idle},
-	{State: selectingStore, Fallback: true, NewState: selectingStore},
-	{State: gettingSoupNames, Event: protocol.SOUP_NAMES, Action: showSoupNames, NewState: gettingSoupNames},
-	{State: gettingSoupNames, Event: protocol.OPERATION_CANCELED, Action: cancel, NewState: idle},
-	{State: gettingSoupNames, Fallback: true, NewState: gettingSoupNames},
+	{State: selectingStore, Event: protocol.RESULT, Guard: messages.ResultFailed, Action: failed, NewState: idle},
+	{State: selectingStore, Event: protocol.RESULT, NewState: gettingSoupNames},
+	{State: gettingSoupNames, Event: protocol.SOUP_NAMES, Action: showSoupNames, NewState: gettingAppList},
 	{State: gettingAppList, Event: protocol.APP_NAMES, Action: showAppList, NewState: idle},
-	{State: gettingAppList, Event: protocol.OPERATION_CANCELED, Action: cancel, NewState: idle},
-	{State: gettingAppList, Fallback: true, NewState: gettingAppList},
+	{AnyState: true, Event: protocol.OPERATION_CANCELED, Action: cancel, NewState: idle},
+	{AnyState: true, Fallback: true, KeepState: true},
 }
 
-var (
-	state = idle
-)
+// Table describes the module's transitions; start enters
+// gettingStoreNames.
+var Table = &fsm.Table[int, protocol.Command, int]{
+	Name:        "info",
+	Transitions: transitions,
+	Initial:     []int{idle, gettingStoreNames},
+	States: map[int]string{
+		idle:              "idle",
+		gettingStoreNames: "gettingStoreNames",
+		selectingStore:    "selectingStore",
+		gettingSoupNames:  "gettingSoupNames",
+		gettingAppList:    "gettingAppList",
+	},
+	Actions: map[int]string{
+		selectStore:   "selectStore",
+		showSoupNames: "showSoupNames",
+		showAppList:   "showAppList",
+		cancel:        "cancel",
+		failed:        "failed",
+	},
+}
+
+func init() {
+	Table.MustValidate()
+}
 
-func processIn(event *protocol.DockEvent) {
-	var action int
-	action, state = fsm.Input(event.Command, state, transitions)
+// Module lists the stores, soups and applications of the Newton.
+type Module struct {
+	events  chan<- protocol.Event
+	machine fsm.Machine[int, protocol.Command, int]
+}
+
+func New(events chan<- protocol.Event) *Module {
+	module := &Module{events: events}
+	module.machine = fsm.Machine[int, protocol.Command, int]{
+		State: idle,
+		Table: Table,
+		Entry: map[int]func(){
+			gettingStoreNames: func() { module.send(&messages.GetStoreNames{}) },
+			gettingSoupNames:  func() { module.send(&messages.GetSoupNames{}) },
+			gettingAppList: func() {
+				module.send(&messages.GetAppNames{What: messages.AppNamesAndSoupsAllStores})
+			},
+		},
+	}
+	return module
+}
+
+func (module *Module) fail(err error) {
+	module.events <- &protocol.ErrorEvent{Err: err, Fatal: true}
+}
+
+// send sends message to the Newton.
+func (module *Module) send(message messages.Message) {
+	event, err := messages.Event(message)
+	if err != nil {
+		module.fail(&protocol.DockError{Err: err})
+		return
+	}
+	module.events <- event
+}
+
+func (module *Module) processIn(event *protocol.DockEvent) {
+	action, err := module.machine.Input(event.Command, event)
+	if err != nil {
+		module.events <- &protocol.ErrorEvent{Err: &protocol.DockError{Err: err}}
+		return
+	}
+	var message messages.Message
+	switch action {
+	case selectStore, showSoupNames, showAppList:
+		message, err = messages.Decode(event)
+		if err != nil {
+			module.fail(&protocol.DockError{Err: err})
+			return
+		}
+	}
 	switch action {
-	case getStoreNames:
-		protocol.Events <- protocol.NewDockEvent(
-			protocol.GET_STORE_NAMES,
-			protocol.Out,
-			[]byte{},
-		)
 	case selectStore:
-		var eventData nsof.Data = event.Data
-		stores := eventData.Factory()
-		var data nsof.Data = []byte{2}
-		stores[0].WriteNSOF(&data)
-		protocol.Events <- protocol.NewDockEvent(
-			protocol.SET_CURRENT_STORE,
-			protocol.Out,
-			data,
-		)
-	case getSoupNames:
-		protocol.Events <- protocol.NewDockEvent(
-			protocol.GET_SOUP_NAMES,
-			protocol.Out,
-			[]byte{},
-		)
+		stores := message.(*messages.StoreNames).Stores
+		if len(stores) == 0 {
+			module.fail(&protocol.DockError{Err: errors.New("no stores")})
+			return
+		}
+		module.send(&messages.SetCurrentStore{Store: stores[0]})
 	case showSoupNames:
-		var eventData nsof.Data = event.Data
-		soups := eventData.Factory()
-		log.Println(soups)
-		protocol.Events <- protocol.NewDockEvent(
-			protocol.GET_APP_NAMES,
-			protocol.Out,
-			[]byte{0, 0, 0, 0},
-		)
-		state = gettingAppList
+		log.Println(message.(*messages.SoupNames).Names)
 	case showAppList:
-		var eventData nsof.Data = event.Data
-		apps := eventData.Factory()
-		log.Println(apps)
-		protocol.Events <- protocol.NewDockEvent(
-			protocol.OPERATION_DONE,
-			protocol.Out,
-			[]byte{},
-		)
+		log.Println(message.(*messages.AppNames).Names())
+		module.send(&messages.OperationDone{})
+		// The session is kept alive with HELLOs, so it has to be ended.
+		module.send(&messages.Disconnect{})
 	case cancel:
-		protocol.Events <- protocol.NewDockEvent(
-			protocol.OP_CANCELED_ACK,
-			protocol.Out,
-			[]byte{},
-		)
+		module.send(&messages.OpCanceledAck{})
+		module.fail(protocol.ErrCanceled)
+	case failed:
+		module.fail(messages.ResultErr(event))
+	}
+}
+
+// start lists the stores once the session is up.
+func (module *Module) start() {
+	if module.machine.State != idle {
+		return
 	}
+	module.machine.Goto(gettingStoreNames)
 }
 
-func Process(event protocol.Event) {
+func (module *Module) Claims(command protocol.Command) bool {
+	return module.machine.Handles(command)
+}
+
+func (module *Module) SetTrace(trace func(step fsm.Step)) {
+	module.machine.Trace = trace
+}
+
+func (module *Module) Process(event protocol.Event) {
 	switch event.(type) {
+	case *protocol.ConnectedEvent:
+		if device := event.(*protocol.ConnectedEvent).Device; device != nil {
+			log.Printf("Docked Newton:\n%s", device)
+		}
+		module.start()
 	case *protocol.DockEvent:
 		if event.(*protocol.DockEvent).Direction == protocol.In {
-			processIn(event.(*protocol.DockEvent))
+			module.processIn(event.(*protocol.DockEvent))
+		}
+	case *protocol.InterruptEvent:
+		if module.machine.State != idle {
+			module.machine.Goto(idle)
+			module.fail(protocol.ErrCanceled)
+		}
+	case *protocol.CancelEvent:
+		if module.machine.State != idle {
+			module.machine.Goto(idle)
+			module.events <- &protocol.AttentionEvent{Direction: protocol.Out, Destructive: true}
+			module.send(&messages.OperationCanceled{})
 		}
 	}
 }
@@ -3,6 +3,8 @@ package install
 import (
 	"gdcl/v3/fsm"
 	"gdcl/v3/protocol"
+	"gdcl/v3/protocol/messages"
+	"log"
 )
 
 const (
@@ -13,58 +15,142 @@ const (
 
 const (
 	noAction int = iota
-	sendRequest
 	sendData
 	installDone
 	cancel
+	failed
 )
 
 var transitions = []fsm.Transition[int, protocol.Command, int]{
-	{State: idle, Event: protocol.APP_CONNECTED, Action: sendRequest, NewState: installing},
 	{State: idle, Fallback: true, NewState: idle},
+	{State: installing, Event: protocol.RESULT, Guard: messages.ResultFailed, Action: failed, NewState: idle},
 	{State: installing, Event: protocol.RESULT, Action: sendData, NewState: sent},
-	{State: installing, Event: protocol.OPERATION_CANCELED, Action: cancel, NewState: idle},
+	{State: sent, Event: protocol.RESULT, Guard: messages.ResultFailed, Action: failed, NewState: idle},
 	{State: sent, Event: protocol.RESULT, Action: installDone, NewState: idle},
+	{AnyState: true, Event: protocol.OPERATION_CANCELED, Action: cancel, NewState: idle},
 }
 
-var (
-	state = idle
-)
+// Table describes the module's transitions; start enters installing.
+var Table = &fsm.Table[int, protocol.Command, int]{
+	Name:        "install",
+	Transitions: transitions,
+	Initial:     []int{idle, installing},
+	States: map[int]string{
+		idle:       "idle",
+		installing: "installing",
+		sent:       "sent",
+	},
+	Actions: map[int]string{
+		sendData:    "sendData",
+		installDone: "installDone",
+		cancel:      "cancel",
+		failed:      "failed",
+	},
+}
+
+func init() {
+	Table.MustValidate()
+}
+
+// Module installs a package on the Newton.
+type Module struct {
+	events      chan<- protocol.Event
+	machine     fsm.Machine[int, protocol.Command, int]
+	packageData []byte
+	// reported is the percentage of the package last logged.
+	reported int
+}
+
+func New(events chan<- protocol.Event, packageData []byte) *Module {
+	return &Module{
+		events:      events,
+		machine:     fsm.Machine[int, protocol.Command, int]{State: idle, Table: Table},
+		packageData: packageData,
+	}
+}
 
-var PackageData []byte
+func (module *Module) fail(err error) {
+	module.events <- &protocol.ErrorEvent{Err: err, Fatal: true}
+}
 
-func processIn(event *protocol.DockEvent) {
-	var action int
-	action, state = fsm.Input(event.Command, state, transitions)
+// send sends message to the Newton.
+func (module *Module) send(message messages.Message) {
+	event, err := messages.Event(message)
+	if err != nil {
+		module.fail(&protocol.DockError{Err: err})
+		return
+	}
+	module.events <- event
+}
+
+func (module *Module) processIn(event *protocol.DockEvent) {
+	action, err := module.machine.Input(event.Command, event)
+	if err != nil {
+		module.events <- &protocol.ErrorEvent{Err: &protocol.DockError{Err: err}}
+		return
+	}
 	switch action {
-	case sendRequest:
-		protocol.Events <- protocol.NewDockEvent(
-			protocol.REQUEST_TO_INSTALL,
-			protocol.Out,
-			[]byte{})
 	case sendData:
-		protocol.Events <- protocol.NewDockEvent(
-			protocol.LOAD_PACKAGE,
-			protocol.Out,
-			PackageData)
+		module.send(&messages.LoadPackage{Package: module.packageData})
 	case installDone:
-		protocol.Events <- protocol.NewDockEvent(
-			protocol.DISCONNECT,
-			protocol.Out,
-			[]byte{})
+		module.send(&messages.Disconnect{})
 	case cancel:
-		protocol.Events <- protocol.NewDockEvent(
-			protocol.OP_CANCELED_ACK,
-			protocol.Out,
-			[]byte{})
+		module.send(&messages.OpCanceledAck{})
+		module.fail(protocol.ErrCanceled)
+	case failed:
+		module.fail(messages.ResultErr(event))
 	}
 }
 
-func Process(event protocol.Event) {
+// processProgress logs the progress of the package upload in steps of 10%.
+func (module *Module) processProgress(event *protocol.ProgressEvent) {
+	if event.Command != protocol.LOAD_PACKAGE || event.Total == 0 {
+		return
+	}
+	percent := event.Done * 100 / event.Total
+	if percent/10 > module.reported/10 {
+		module.reported = percent
+		log.Printf("Sent %d%% of the package", percent)
+	}
+}
+
+func (module *Module) start() {
+	if module.machine.State != idle {
+		return
+	}
+	module.machine.Goto(installing)
+	module.reported = 0
+	module.send(&messages.RequestToInstall{})
+}
+
+func (module *Module) Claims(command protocol.Command) bool {
+	return module.machine.Handles(command)
+}
+
+func (module *Module) SetTrace(trace func(step fsm.Step)) {
+	module.machine.Trace = trace
+}
+
+func (module *Module) Process(event protocol.Event) {
 	switch event.(type) {
+	case *protocol.ConnectedEvent:
+		module.start()
 	case *protocol.DockEvent:
 		if event.(*protocol.DockEvent).Direction == protocol.In {
-			processIn(event.(*protocol.DockEvent))
+			module.processIn(event.(*protocol.DockEvent))
+		}
+	case *protocol.InterruptEvent:
+		if module.machine.State != idle {
+			module.machine.Goto(idle)
+			module.fail(protocol.ErrCanceled)
+		}
+	case *protocol.ProgressEvent:
+		module.processProgress(event.(*protocol.ProgressEvent))
+	case *protocol.CancelEvent:
+		if module.machine.State != idle {
+			module.machine.Goto(idle)
+			module.events <- &protocol.AttentionEvent{Direction: protocol.Out, Destructive: true}
+			module.send(&messages.OperationCanceled{})
 		}
 	}
 }
@@ -0,0 +1,150 @@
+package protocol
+
+import "fmt"
+
+// ResultError is a non-zero error code sent by the Newton in a RESULT
+// command. Compare with errors.Is against the codes below.
+type ResultError int32
+
+// Dock errors
+const (
+	ErrBadStoreSignature    ResultError = -28001
+	ErrBadEntry             ResultError = -28002
+	ErrAborted              ResultError = -28003
+	ErrBadQuery             ResultError = -28004
+	ErrReadEntry            ResultError = -28005
+	ErrBadCurrentSoup       ResultError = -28006
+	ErrBadCommandLength     ResultError = -28007
+	ErrEntryNotFound        ResultError = -28008
+	ErrBadConnection        ResultError = -28009
+	ErrFileNotFound         ResultError = -28010
+	ErrIncompatibleProtocol ResultError = -28011
+	ErrProtocol             ResultError = -28012
+	ErrDockingCanceled      ResultError = -28013
+	ErrStoreNotFound        ResultError = -28014
+	ErrSoupNotFound         ResultError = -28015
+	ErrBadHeader            ResultError = -28016
+	ErrOutOfMemory          ResultError = -28017
+	ErrNewtonVersionTooNew  ResultError = -28018
+	ErrPackageCantLoad      ResultError = -28019
+	ErrExtensionRegistered  ResultError = -28020
+	ErrRemoteImport         ResultError = -28021
+	ErrBadPassword          ResultError = -28022
+	ErrRetryPassword        ResultError = -28023
+	ErrIdleTooLong          ResultError = -28024
+	ErrOutOfPower           ResultError = -28025
+	ErrBadCursor            ResultError = -28026
+	ErrAlreadyBusy          ResultError = -28027
+	ErrDesktopError         ResultError = -28028
+	ErrCantConnectToModem   ResultError = -28029
+	ErrDisconnected         ResultError = -28030
+	ErrAccessDenied         ResultError = -28031
+)
+
+// Store errors
+const (
+	ErrNoSuchStore         ResultError = -10005
+	ErrStoreWriteProtected ResultError = -10006
+	ErrObjectNotFound      ResultError = -10007
+	ErrStoreWrite          ResultError = -10010
+	ErrCardFull            ResultError = -10013
+	ErrStoreNeedsFormat    ResultError = -10016
+	ErrStoreFull           ResultError = -10018
+	ErrCardBattery         ResultError = -10019
+)
+
+// System errors
+const (
+	ErrNoMemory ResultError = -7000
+)
+
+// Package errors
+const (
+	ErrBadPackage             ResultError = -10401
+	ErrPackageExists          ResultError = -10402
+	ErrBadPackageVersion      ResultError = -10403
+	ErrUnexpectedEndOfPackage ResultError = -10404
+	ErrUnexpectedEndOfPart    ResultError = -10405
+	ErrPartTypeRegistered     ResultError = -10406
+	ErrPartTypeNotRegistered  ResultError = -10407
+	ErrNoSuchPackage          ResultError = -10408
+	ErrNewerPackageExists     ResultError = -10409
+	ErrNewerApplicationExists ResultError = -10410
+)
+
+var resultMessages = map[ResultError]string{
+	ErrBadStoreSignature:      "bad store signature",
+	ErrBadEntry:               "bad entry",
+	ErrAborted:                "aborted",
+	ErrBadQuery:               "bad query",
+	ErrReadEntry:              "error reading entry",
+	ErrBadCurrentSoup:         "bad current soup",
+	ErrBadCommandLength:       "bad command length",
+	ErrEntryNotFound:          "entry not found",
+	ErrBadConnection:          "bad connection",
+	ErrFileNotFound:           "file not found",
+	ErrIncompatibleProtocol:   "incompatible protocol",
+	ErrProtocol:               "protocol error",
+	ErrDockingCanceled:        "docking canceled",
+	ErrStoreNotFound:          "store not found",
+	ErrSoupNotFound:           "soup not found",
+	ErrBadHeader:              "bad header",
+	ErrOutOfMemory:            "the Newton is out of memory",
+	ErrNewtonVersionTooNew:    "Newton system version too new",
+	ErrPackageCantLoad:        "the package cannot be loaded",
+	ErrExtensionRegistered:    "protocol extension already registered",
+	ErrRemoteImport:           "remote import failed",
+	ErrBadPassword:            "wrong password",
+	ErrRetryPassword:          "retry the password",
+	ErrIdleTooLong:            "the session was idle too long",
+	ErrOutOfPower:             "the Newton is out of power",
+	ErrBadCursor:              "bad cursor",
+	ErrAlreadyBusy:            "the Newton is busy",
+	ErrDesktopError:           "desktop error",
+	ErrCantConnectToModem:     "cannot connect to the modem",
+	ErrDisconnected:           "disconnected",
+	ErrAccessDenied:           "access denied",
+	ErrNoSuchStore:            "no such store",
+	ErrStoreWriteProtected:    "the store is write-protected",
+	ErrObjectNotFound:         "object not found on the store",
+	ErrStoreWrite:             "error writing to the store",
+	ErrCardFull:               "the card is full",
+	ErrStoreNeedsFormat:       "the store needs to be formatted",
+	ErrStoreFull:              "the store is full",
+	ErrCardBattery:            "the card's battery is low",
+	ErrNoMemory:               "not enough memory on the Newton",
+	ErrBadPackage:             "bad package",
+	ErrPackageExists:          "the package is already installed",
+	ErrBadPackageVersion:      "bad package version",
+	ErrUnexpectedEndOfPackage: "unexpected end of package",
+	ErrUnexpectedEndOfPart:    "unexpected end of package part",
+	ErrPartTypeRegistered:     "package part type already registered",
+	ErrPartTypeNotRegistered:  "package part type not registered",
+	ErrNoSuchPackage:          "no such package",
+	ErrNewerPackageExists:     "a newer version of the package is installed",
+	ErrNewerApplicationExists: "a newer version of the application is installed",
+}
+
+// resultRanges describe the codes without a message of their own by the
+// range they are in.
+var resultRanges = []struct {
+	first, last ResultError
+	message     string
+}{
+	{-10099, -10001, "store error"},
+	{-10499, -10400, "package error"},
+	{-28999, -28000, "dock error"},
+}
+
+func (err ResultError) Error() string {
+	message, ok := resultMessages[err]
+	for _, r := range resultRanges {
+		if !ok && err >= r.first && err <= r.last {
+			message, ok = r.message, true
+		}
+	}
+	if ok {
+		return fmt.Sprintf("%s (Newton error %d)", message, int32(err))
+	}
+	return fmt.Sprintf("Newton error %d", int32(err))
+}
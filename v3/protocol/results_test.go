@@ -0,0 +1,35 @@
+package protocol
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestResultErrorMessages(t *testing.T) {
+	tests := []struct {
+		err  ResultError
+		want string
+	}{
+		{ErrBadPassword, "wrong password (Newton error -28022)"},
+		{ErrPackageExists, "the package is already installed (Newton error -10402)"},
+		{ErrStoreFull, "the store is full (Newton error -10018)"},
+		{ErrNoMemory, "not enough memory on the Newton (Newton error -7000)"},
+		{-10002, "store error (Newton error -10002)"},
+		{-10450, "package error (Newton error -10450)"},
+		{-28100, "dock error (Newton error -28100)"},
+		{-48214, "Newton error -48214"},
+	}
+	for _, test := range tests {
+		if got := test.err.Error(); got != test.want {
+			t.Errorf("%d: got %q, want %q", int32(test.err), got, test.want)
+		}
+	}
+}
+
+func TestResultErrorIs(t *testing.T) {
+	err := fmt.Errorf("installing: %w", ResultError(-10018))
+	if !errors.Is(err, ErrStoreFull) || errors.Is(err, ErrCardFull) {
+		t.Errorf("errors.Is does not match %v against ErrStoreFull only", err)
+	}
+}
@@ -1,56 +1,58 @@
 package serial
 
 import (
-	"gdcl/v3/protocol"
+	"context"
 	"go.bug.st/serial"
-	"log"
+	"io"
+	"sync/atomic"
 )
 
-var (
-	fd serial.Port
-)
+// Port is a transport.Transport on a local serial port.
+type Port struct {
+	name   string
+	speed  int
+	fd     serial.Port
+	closed atomic.Bool
+}
+
+func New(name string, speed int) *Port {
+	return &Port{name: name, speed: speed}
+}
 
-func SerialLoop(port string, speed int) {
+func (port *Port) Open(ctx context.Context) error {
 	var err error
-	log.Println("Starting serial loop on port", port)
 	mode := &serial.Mode{
-		BaudRate: speed,
+		BaudRate: port.speed,
 	}
-	fd, err = serial.Open(port, mode)
-	if err != nil {
-		log.Fatalf("Error opening %s: %s", port, err)
-	}
-
-	for {
-		buf := make([]byte, 65536)
-		n, err := fd.Read(buf)
-		if err != nil {
-			log.Fatal(err)
-		}
-		if n == 0 {
-			protocol.Events <- protocol.NewDockEvent(protocol.APP_QUIT, protocol.In, []byte{})
-			break
-		}
-		protocol.Events <- &protocol.SerialEvent{
-			Direction: protocol.In,
-			Data:      buf[:n],
-		}
+	port.fd, err = serial.Open(port.name, mode)
+	return err
+}
 
+func (port *Port) Read(buf []byte) (int, error) {
+	n, err := port.fd.Read(buf)
+	if port.closed.Load() {
+		return 0, io.EOF
 	}
-	log.Println("Serial loop done")
+	return n, err
 }
 
-func Process(event protocol.Event) {
-	if protocol.IsQuitEvent(event) {
-		fd.Close()
-		return
+func (port *Port) Write(data []byte) (int, error) {
+	n, err := port.fd.Write(data)
+	if err != nil {
+		return n, err
 	}
+	return n, port.fd.Drain()
+}
 
-	switch event.(type) {
-	case *protocol.SerialEvent:
-		if event.(*protocol.SerialEvent).Direction == protocol.Out {
-			fd.Write(event.(*protocol.SerialEvent).Data)
-			fd.Drain()
-		}
-	}
+func (port *Port) Close() error {
+	port.closed.Store(true)
+	return port.fd.Close()
+}
+
+func (port *Port) SetDTR(dtr bool) error {
+	return port.fd.SetDTR(dtr)
+}
+
+func (port *Port) SetRTS(rts bool) error {
+	return port.fd.SetRTS(rts)
 }
@@ -0,0 +1,84 @@
+package stream
+
+import (
+	"bytes"
+	"encoding/binary"
+	"gdcl/v3/protocol"
+)
+
+// Dock packets on a TCP/IP connection are sent as they are, without MNP
+// framing, so this layer replaces both framing and mnp for such links.
+
+const headerLength = 16
+
+var header = []byte("newtdock")
+
+// Layer is the dock packet stream of one session.
+type Layer struct {
+	events chan<- protocol.Event
+	data   []byte
+}
+
+func New(events chan<- protocol.Event) *Layer {
+	return &Layer{events: events}
+}
+
+func (layer *Layer) processIn(event *protocol.SerialEvent) {
+	layer.data = append(layer.data, event.Data...)
+	for {
+		start := bytes.Index(layer.data, header)
+		if start < 0 {
+			if len(layer.data) >= len(header) {
+				layer.data = layer.data[len(layer.data)-len(header)+1:]
+			}
+			return
+		}
+		layer.data = layer.data[start:]
+		if len(layer.data) < headerLength {
+			return
+		}
+		length := binary.BigEndian.Uint32(layer.data[12:16])
+		total := headerLength + int(length) + int(-length&3)
+		if len(layer.data) < total {
+			return
+		}
+		dockPacket := &protocol.DockEvent{
+			Direction: protocol.In,
+			Command:   protocol.Command(binary.BigEndian.Uint32(layer.data[8:12])),
+			Length:    length,
+			Data:      append([]byte{}, layer.data[headerLength:headerLength+int(length)]...),
+		}
+		layer.data = layer.data[total:]
+		layer.events <- dockPacket
+	}
+}
+
+func (layer *Layer) processOut(event *protocol.DockEvent) {
+	data := event.Encode()
+	layer.events <- &protocol.SerialEvent{
+		Direction: protocol.Out,
+		Data:      data,
+	}
+	layer.events <- &protocol.ProgressEvent{
+		Command: event.Command,
+		Done:    len(data),
+		Total:   len(data),
+	}
+}
+
+func (layer *Layer) Process(event protocol.Event) {
+	switch event.(type) {
+	case *protocol.SerialEvent:
+		if event.(*protocol.SerialEvent).Direction == protocol.In {
+			layer.processIn(event.(*protocol.SerialEvent))
+		}
+	case *protocol.DockEvent:
+		if event.(*protocol.DockEvent).Direction == protocol.Out {
+			layer.processOut(event.(*protocol.DockEvent))
+		}
+	case *protocol.DisconnectEvent:
+		// A DISCONNECT from the dock layer is still written while the event
+		// loop winds down, there is no link to take down after it.
+		layer.events <- &protocol.QuitEvent{}
+	}
+}
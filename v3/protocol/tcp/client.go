@@ -0,0 +1,79 @@
+package tcp
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"syscall"
+	"time"
+)
+
+// Client is a transport.Transport connecting to a TCP server, such as the
+// serial port bridge of the Einstein emulator. Opening the connection is
+// retried until Retries attempts have failed, so gdcl can be started before
+// the emulator is listening. A connection closed or reset by the emulator
+// later on is not reopened, as the Newton's dock session is lost with it:
+// Read returns io.EOF, so the session quits cleanly, as with Server.
+type Client struct {
+	Retries    int
+	RetryDelay time.Duration
+	address    string
+	conn       net.Conn
+}
+
+func NewClient(address string) *Client {
+	return &Client{
+		Retries:    10,
+		RetryDelay: time.Second,
+		address:    address,
+	}
+}
+
+func (client *Client) Open(ctx context.Context) error {
+	var err error
+	var dialer net.Dialer
+	for i := 0; i <= client.Retries; i++ {
+		if i > 0 {
+			log.Printf("Error connecting to %s: %s, retrying", client.address, err)
+			select {
+			case <-time.After(client.RetryDelay):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		client.conn, err = dialer.DialContext(ctx, "tcp", client.address)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+	return err
+}
+
+func (client *Client) Read(buf []byte) (int, error) {
+	n, err := client.conn.Read(buf)
+	if errors.Is(err, net.ErrClosed) || errors.Is(err, syscall.ECONNRESET) {
+		return n, io.EOF
+	}
+	return n, err
+}
+
+func (client *Client) Write(data []byte) (int, error) {
+	return client.conn.Write(data)
+}
+
+func (client *Client) Close() error {
+	return client.conn.Close()
+}
+
+func (client *Client) SetDTR(dtr bool) error {
+	return nil
+}
+
+func (client *Client) SetRTS(rts bool) error {
+	return nil
+}
@@ -0,0 +1,75 @@
+package tcp
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"syscall"
+)
+
+// DockPort is the TCP port Newtons with the Newton Internet Enabler connect
+// to when docking over TCP/IP.
+const DockPort = 3679
+
+// Server is a transport.Transport waiting for a Newton to connect. Open
+// blocks until the first connection has been accepted or ctx is done.
+type Server struct {
+	address string
+	conn    net.Conn
+}
+
+func NewServer(address string) *Server {
+	return &Server{address: address}
+}
+
+func (server *Server) Open(ctx context.Context) error {
+	var config net.ListenConfig
+	listener, err := config.Listen(ctx, "tcp", server.address)
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+	stop := context.AfterFunc(ctx, func() { listener.Close() })
+	defer stop()
+	log.Println("Waiting for connection on", listener.Addr())
+	server.conn, err = listener.Accept()
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return err
+	}
+	log.Println("Connection from", server.conn.RemoteAddr())
+	return nil
+}
+
+func (server *Server) Read(buf []byte) (int, error) {
+	n, err := server.conn.Read(buf)
+	if errors.Is(err, net.ErrClosed) || errors.Is(err, syscall.ECONNRESET) {
+		return n, io.EOF
+	}
+	return n, err
+}
+
+func (server *Server) Write(data []byte) (int, error) {
+	return server.conn.Write(data)
+}
+
+func (server *Server) Close() error {
+	return server.conn.Close()
+}
+
+func (server *Server) SetDTR(dtr bool) error {
+	return nil
+}
+
+func (server *Server) SetRTS(rts bool) error {
+	return nil
+}
+
+// Direct returns true, Newtons docking over TCP/IP do not use MNP.
+func (server *Server) Direct() bool {
+	return true
+}
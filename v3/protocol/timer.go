@@ -0,0 +1,34 @@
+package protocol
+
+import (
+	"fmt"
+	"time"
+)
+
+// Timer sends a TimerEvent to its session's events when it expires, so that
+// timeouts are handled in the event loop like any other input.
+type Timer struct {
+	timer *time.Timer
+}
+
+type TimerEvent struct {
+	Timer *Timer
+}
+
+func StartTimer(events chan<- Event, d time.Duration) *Timer {
+	t := &Timer{}
+	t.timer = time.AfterFunc(d, func() {
+		events <- &TimerEvent{Timer: t}
+	})
+	return t
+}
+
+// Stop prevents the timer from firing. A TimerEvent already queued is still
+// delivered, so receivers need to check whether the timer is still theirs.
+func (t *Timer) Stop() {
+	t.timer.Stop()
+}
+
+func (event TimerEvent) String() string {
+	return fmt.Sprintf("Timer %p", event.Timer)
+}
@@ -0,0 +1,67 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"gdcl/v3/protocol"
+	"io"
+	"log"
+)
+
+// Transport is the byte stream a Newton is connected through, e.g. a serial
+// port. Open should give up when ctx is done. Read should return io.EOF
+// once the connection has been closed.
+type Transport interface {
+	io.ReadWriteCloser
+	Open(ctx context.Context) error
+	SetDTR(dtr bool) error
+	SetRTS(rts bool) error
+}
+
+// Direct is implemented by transports on which dock packets are exchanged
+// without MNP, like TCP/IP docking.
+type Direct interface {
+	Direct() bool
+}
+
+// Layer connects a transport to a session: Loop reads from the transport,
+// Process writes the outgoing bytes.
+type Layer struct {
+	transport Transport
+	events    chan<- protocol.Event
+}
+
+func New(t Transport, events chan<- protocol.Event) *Layer {
+	return &Layer{transport: t, events: events}
+}
+
+func (layer *Layer) Loop() {
+	log.Println("Starting transport loop")
+	for {
+		buf := make([]byte, 65536)
+		n, err := layer.transport.Read(buf)
+		if err != nil && !errors.Is(err, io.EOF) {
+			layer.events <- &protocol.ErrorEvent{Err: &protocol.TransportError{Err: err}, Fatal: true}
+		}
+		if n == 0 || err != nil {
+			layer.events <- &protocol.QuitEvent{}
+			break
+		}
+		layer.events <- &protocol.SerialEvent{
+			Direction: protocol.In,
+			Data:      buf[:n],
+		}
+	}
+	log.Println("Transport loop done")
+}
+
+func (layer *Layer) Process(event protocol.Event) {
+	switch event.(type) {
+	case *protocol.SerialEvent:
+		if event.(*protocol.SerialEvent).Direction == protocol.Out {
+			if _, err := layer.transport.Write(event.(*protocol.SerialEvent).Data); err != nil {
+				layer.events <- &protocol.ErrorEvent{Err: &protocol.TransportError{Err: err}, Fatal: true}
+			}
+		}
+	}
+}
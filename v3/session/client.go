@@ -0,0 +1,409 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"gdcl/v3/fsm"
+	"gdcl/v3/nsof"
+	"gdcl/v3/protocol"
+	"gdcl/v3/protocol/dock"
+	"gdcl/v3/protocol/messages"
+	"gdcl/v3/protocol/transport"
+	"io"
+	"time"
+)
+
+var (
+	ErrClosed   = errors.New("session closed")
+	ErrCanceled = protocol.ErrCanceled
+	ErrBusy     = errors.New("operation already in progress")
+)
+
+// cancelTimeout is how long a canceled call waits for the Newton to
+// acknowledge before it disconnects.
+var cancelTimeout = 10 * time.Second
+
+// Store is a store on the Newton, as returned by StoreNames.
+type Store struct {
+	Name  string
+	frame *nsof.Frame
+}
+
+const (
+	connecting = iota
+	ready
+	gettingStoreNames
+	selectingStore
+	gettingSoupNames
+	gettingAppNames
+	requestingInstall
+	installing
+	canceling
+)
+
+const (
+	noAction int = iota
+	returnStoreNames
+	getSoupNames
+	returnSoupNames
+	returnAppNames
+	sendPackage
+	returnDone
+	returnError
+	cancel
+	returnCanceled
+)
+
+var transitions = []fsm.Transition[int, protocol.Command, int]{
+	{State: gettingStoreNames, Event: protocol.STORE_NAMES, Action: returnStoreNames, NewState: ready},
+	{State: selectingStore, Event: protocol.RESULT, Guard: messages.ResultFailed, Action: returnError, NewState: ready},
+	{State: selectingStore, Event: protocol.RESULT, Action: getSoupNames, NewState: gettingSoupNames},
+	{State: gettingSoupNames, Event: protocol.SOUP_NAMES, Action: returnSoupNames, NewState: ready},
+	{State: gettingAppNames, Event: protocol.APP_NAMES, Action: returnAppNames, NewState: ready},
+	{State: requestingInstall, Event: protocol.RESULT, Guard: messages.ResultFailed, Action: returnError, NewState: ready},
+	{State: requestingInstall, Event: protocol.RESULT, Action: sendPackage, NewState: installing},
+	{State: installing, Event: protocol.RESULT, Guard: messages.ResultFailed, Action: returnError, NewState: ready},
+	{State: installing, Event: protocol.RESULT, Action: returnDone, NewState: ready},
+	{State: canceling, Event: protocol.OP_CANCELED_ACK, Action: returnCanceled, NewState: ready},
+	{State: connecting, Fallback: true, KeepState: true},
+	{State: ready, Fallback: true, KeepState: true},
+	{AnyState: true, Event: protocol.OPERATION_CANCELED, Action: cancel, NewState: ready},
+	{AnyState: true, Fallback: true, KeepState: true},
+}
+
+// Table describes the client's transitions. The request states and
+// canceling are entered directly, ready once the session is up.
+var Table = &fsm.Table[int, protocol.Command, int]{
+	Name:        "client",
+	Transitions: transitions,
+	Initial:     []int{connecting, ready, gettingStoreNames, selectingStore, gettingAppNames, requestingInstall, canceling},
+	States: map[int]string{
+		connecting:        "connecting",
+		ready:             "ready",
+		gettingStoreNames: "gettingStoreNames",
+		selectingStore:    "selectingStore",
+		gettingSoupNames:  "gettingSoupNames",
+		gettingAppNames:   "gettingAppNames",
+		requestingInstall: "requestingInstall",
+		installing:        "installing",
+		canceling:         "canceling",
+	},
+	Actions: map[int]string{
+		returnStoreNames: "returnStoreNames",
+		getSoupNames:     "getSoupNames",
+		returnSoupNames:  "returnSoupNames",
+		returnAppNames:   "returnAppNames",
+		sendPackage:      "sendPackage",
+		returnDone:       "returnDone",
+		returnError:      "returnError",
+		cancel:           "cancel",
+		returnCanceled:   "returnCanceled",
+	},
+}
+
+func init() {
+	Table.MustValidate()
+}
+
+// request is sent through the event loop by the blocking Session methods
+// and answered on its reply channel.
+type request struct {
+	state       int
+	message     messages.Message
+	packageData []byte
+	reply       chan reply
+	// canceled is the reason the caller gave up on the request.
+	canceled error
+}
+
+// cancelation asks the client to cancel request.
+type cancelation struct {
+	request *request
+	err     error
+}
+
+type reply struct {
+	value any
+	err   error
+}
+
+// client is the module behind the blocking Session methods. It runs one
+// request at a time.
+type client struct {
+	events    chan<- protocol.Event
+	machine   fsm.Machine[int, protocol.Command, int]
+	connected chan struct{}
+	request   *request
+}
+
+func newClient(events chan<- protocol.Event) *client {
+	return &client{
+		events:    events,
+		machine:   fsm.Machine[int, protocol.Command, int]{State: connecting, Table: Table},
+		connected: make(chan struct{}),
+	}
+}
+
+// Dial opens t and docks with the Newton. It returns once the Newton has
+// accepted the connection, the session ended or ctx is done. ctx only
+// bounds the docking, the session stays up until Disconnect.
+func Dial(ctx context.Context, t transport.Transport, config dock.Config) (*Session, error) {
+	session := New(t, config)
+	session.client = newClient(session.events)
+	session.AddModule(session.client)
+	if err := t.Open(ctx); err != nil {
+		return nil, &protocol.TransportError{Err: err}
+	}
+	go session.loop(context.Background())
+	select {
+	case <-session.client.connected:
+		return session, nil
+	case <-session.done:
+		return nil, session.closed()
+	case <-ctx.Done():
+		session.Disconnect(context.Background())
+		return nil, ctx.Err()
+	}
+}
+
+// closed returns the error for operations on a session which has ended.
+func (session *Session) closed() error {
+	if session.err != nil {
+		return session.err
+	}
+	return ErrClosed
+}
+
+// The operations below block until the Newton has answered. When ctx is
+// done first, the operation is canceled on the Newton and ctx.Err() is
+// returned.
+
+// StoreNames returns the stores of the Newton.
+func (session *Session) StoreNames(ctx context.Context) ([]Store, error) {
+	value, err := session.call(ctx, &request{state: gettingStoreNames, message: &messages.GetStoreNames{}})
+	if err != nil {
+		return nil, err
+	}
+	return value.([]Store), nil
+}
+
+// SoupNames makes store the current store and returns the names of its
+// soups.
+func (session *Session) SoupNames(ctx context.Context, store Store) ([]string, error) {
+	value, err := session.call(ctx, &request{
+		state:   selectingStore,
+		message: &messages.SetCurrentStore{Store: store.frame},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return value.([]string), nil
+}
+
+// AppNames returns the names of the applications on the Newton.
+func (session *Session) AppNames(ctx context.Context) ([]string, error) {
+	value, err := session.call(ctx, &request{
+		state:   gettingAppNames,
+		message: &messages.GetAppNames{What: messages.AppNamesAndSoupsAllStores},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return value.([]string), nil
+}
+
+// InstallPackage reads a package from r and installs it on the Newton.
+func (session *Session) InstallPackage(ctx context.Context, r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	_, err = session.call(ctx, &request{
+		state:       requestingInstall,
+		message:     &messages.RequestToInstall{},
+		packageData: data,
+	})
+	return err
+}
+
+// Disconnect ends the session and waits until the link has been closed.
+// If ctx is done first, the transport is closed without waiting for the
+// Newton.
+func (session *Session) Disconnect(ctx context.Context) error {
+	select {
+	case session.events <- &protocol.DisconnectEvent{}:
+	case <-session.done:
+		return nil
+	}
+	select {
+	case <-session.done:
+		return nil
+	case <-ctx.Done():
+		session.transport.Close()
+		<-session.done
+		return ctx.Err()
+	}
+}
+
+func (session *Session) call(ctx context.Context, req *request) (any, error) {
+	session.calls.Lock()
+	defer session.calls.Unlock()
+	req.reply = make(chan reply, 1)
+	select {
+	case session.events <- req:
+	case <-session.done:
+		return nil, session.closed()
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	select {
+	case r := <-req.reply:
+		return r.value, r.err
+	case <-session.done:
+		return nil, session.closed()
+	case <-ctx.Done():
+	}
+	// Wait for the Newton to acknowledge the cancelation, so the next
+	// request does not pick up a late answer to this one.
+	select {
+	case session.events <- &cancelation{request: req, err: ctx.Err()}:
+	case <-session.done:
+		return nil, session.closed()
+	}
+	timer := time.NewTimer(cancelTimeout)
+	defer timer.Stop()
+	select {
+	case r := <-req.reply:
+		return r.value, r.err
+	case <-session.done:
+		return nil, session.closed()
+	case <-timer.C:
+	}
+	// Without the acknowledgement, the session is out of step with the
+	// Newton, so it is ended.
+	disconnectCtx, cancel := context.WithTimeout(context.Background(), cancelTimeout)
+	defer cancel()
+	session.Disconnect(disconnectCtx)
+	return nil, ctx.Err()
+}
+
+func (client *client) finish(value any, err error) {
+	client.request.reply <- reply{value: value, err: err}
+	client.request = nil
+}
+
+// cancel cancels the request in progress on the Newton. The request
+// finishes with err once the Newton has acknowledged.
+func (client *client) cancel(req *request, err error) {
+	if client.request != req || client.machine.State == canceling {
+		return
+	}
+	req.canceled = err
+	client.machine.Goto(canceling)
+	client.events <- &protocol.AttentionEvent{Direction: protocol.Out, Destructive: true}
+	client.send(&messages.OperationCanceled{})
+}
+
+func (client *client) start(req *request) {
+	if client.machine.State != ready {
+		req.reply <- reply{err: ErrBusy}
+		return
+	}
+	client.request = req
+	client.machine.Goto(req.state)
+	client.send(req.message)
+}
+
+// send sends message to the Newton.
+func (client *client) send(message messages.Message) {
+	event, err := messages.Event(message)
+	if err != nil {
+		client.events <- &protocol.ErrorEvent{Err: &protocol.DockError{Err: err}, Fatal: true}
+		return
+	}
+	client.events <- event
+}
+
+func (client *client) processIn(event *protocol.DockEvent) {
+	action, err := client.machine.Input(event.Command, event)
+	if err != nil {
+		client.events <- &protocol.ErrorEvent{Err: &protocol.DockError{Err: err}}
+		return
+	}
+	var message messages.Message
+	switch action {
+	case returnStoreNames, returnSoupNames, returnAppNames:
+		message, err = messages.Decode(event)
+		if err != nil {
+			client.finish(nil, &protocol.DockError{Err: err})
+			return
+		}
+	}
+	switch action {
+	case returnStoreNames:
+		var stores []Store
+		for _, frame := range message.(*messages.StoreNames).Stores {
+			stores = append(stores, Store{Name: messages.SlotString(frame, "name"), frame: frame})
+		}
+		client.finish(stores, nil)
+	case getSoupNames:
+		client.send(&messages.GetSoupNames{})
+	case returnSoupNames:
+		client.finish(message.(*messages.SoupNames).Names, nil)
+	case returnAppNames:
+		client.finish(message.(*messages.AppNames).Names(), nil)
+	case sendPackage:
+		client.send(&messages.LoadPackage{Package: client.request.packageData})
+	case returnDone:
+		client.finish(nil, nil)
+	case returnError:
+		client.finish(nil, messages.ResultErr(event))
+	case cancel:
+		client.send(&messages.OpCanceledAck{})
+		if client.request == nil {
+			break
+		}
+		if client.request.canceled != nil {
+			client.finish(nil, client.request.canceled)
+		} else {
+			client.finish(nil, ErrCanceled)
+		}
+	case returnCanceled:
+		client.finish(nil, client.request.canceled)
+	}
+}
+
+func (client *client) Claims(command protocol.Command) bool {
+	return client.machine.Handles(command)
+}
+
+func (client *client) SetTrace(trace func(step fsm.Step)) {
+	client.machine.Trace = trace
+}
+
+func (client *client) Process(event protocol.Event) {
+	switch event.(type) {
+	case *protocol.ConnectedEvent:
+		if client.machine.State == connecting {
+			client.machine.Goto(ready)
+			close(client.connected)
+		}
+	case *protocol.DockEvent:
+		if event.(*protocol.DockEvent).Direction == protocol.In {
+			client.processIn(event.(*protocol.DockEvent))
+		}
+	case *request:
+		client.start(event.(*request))
+	case *cancelation:
+		client.cancel(event.(*cancelation).request, event.(*cancelation).err)
+	case *protocol.CancelEvent:
+		if client.request != nil {
+			client.cancel(client.request, context.Canceled)
+		}
+	case *protocol.InterruptEvent:
+		if client.request != nil {
+			client.machine.Goto(ready)
+			client.finish(nil, ErrCanceled)
+		}
+	}
+}
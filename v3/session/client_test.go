@@ -0,0 +1,259 @@
+package session
+
+import (
+	"context"
+	"crypto/des"
+	"encoding/binary"
+	"errors"
+	"gdcl/v3/nsof"
+	"gdcl/v3/protocol"
+	"gdcl/v3/protocol/dock"
+	"gdcl/v3/protocol/messages"
+	"io"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// pipe is an in-memory TCP/IP docking connection.
+type pipe struct {
+	net.Conn
+}
+
+func (pipe) Open(ctx context.Context) error { return nil }
+func (pipe) SetDTR(dtr bool) error          { return nil }
+func (pipe) SetRTS(rts bool) error          { return nil }
+func (pipe) Direct() bool                   { return true }
+
+// noPasswordKey is the key of a Newton without a password.
+var noPasswordKey = []byte{0xe4, 0x0f, 0x7e, 0x9f, 0x0a, 0x36, 0x2c, 0xfa}
+
+// newton is the Newton end of a pipe, driven by the test.
+type newton struct {
+	t    *testing.T
+	conn net.Conn
+	in   chan *protocol.DockEvent
+}
+
+func newNewton(t *testing.T, conn net.Conn) *newton {
+	n := &newton{t: t, conn: conn, in: make(chan *protocol.DockEvent, 100)}
+	go n.read()
+	return n
+}
+
+// read passes the dock commands from the desktop to n.in, which is
+// closed once the desktop has closed the connection.
+func (n *newton) read() {
+	defer close(n.in)
+	header := make([]byte, 16)
+	for {
+		if _, err := io.ReadFull(n.conn, header); err != nil {
+			return
+		}
+		length := binary.BigEndian.Uint32(header[12:])
+		data := make([]byte, length+(-length&3))
+		if _, err := io.ReadFull(n.conn, data); err != nil {
+			return
+		}
+		command := protocol.Command(binary.BigEndian.Uint32(header[8:12]))
+		n.in <- protocol.NewDockEvent(command, protocol.In, data[:length])
+	}
+}
+
+func (n *newton) send(message messages.Message) {
+	event, err := messages.Event(message)
+	if err != nil {
+		n.t.Fatal(err)
+	}
+	if _, err := n.conn.Write(event.Encode()); err != nil {
+		n.t.Fatal(err)
+	}
+}
+
+// expect returns the next command from the desktop, which must be
+// command.
+func (n *newton) expect(command protocol.Command) messages.Message {
+	n.t.Helper()
+	select {
+	case event, ok := <-n.in:
+		if !ok {
+			n.t.Fatalf("connection closed, want %s", command)
+		}
+		if event.Command != command {
+			n.t.Fatalf("got %s, want %s", event.Command, command)
+		}
+		message, err := messages.Decode(event)
+		if err != nil {
+			n.t.Fatal(err)
+		}
+		return message
+	case <-time.After(5 * time.Second):
+		n.t.Fatalf("timeout waiting for %s", command)
+	}
+	return nil
+}
+
+// expectClosed waits until the desktop has closed the connection.
+func (n *newton) expectClosed() {
+	n.t.Helper()
+	select {
+	case event, ok := <-n.in:
+		if ok {
+			n.t.Fatalf("got %s, want the connection closed", event.Command)
+		}
+	case <-time.After(5 * time.Second):
+		n.t.Fatal("timeout waiting for the connection to close")
+	}
+}
+
+// dock runs the docking handshake of a Newton without a password.
+func (n *newton) dock() {
+	n.send(&messages.RequestToDock{ProtocolVersion: 9})
+	n.expect(protocol.INITIATE_DOCKING)
+	n.send(&messages.NewtonName{Info: messages.VersionInfo{NewtonID: 1}, Name: "Test"})
+	desktopInfo := n.expect(protocol.DESKTOP_INFO).(*messages.DesktopInfo)
+	n.send(&messages.NewtonInfo{ProtocolVersion: 10, EncryptedKey: 0x0102030405060708})
+	n.expect(protocol.WHICH_ICONS)
+	n.send(&messages.Result{})
+	n.expect(protocol.SET_TIMEOUT)
+	n.send(&messages.Password{Key: encrypt(desktopInfo.EncryptedKey)})
+	if key := n.expect(protocol.PASSWORD).(*messages.Password).Key; key != encrypt(0x0102030405060708) {
+		n.t.Fatalf("got password %016x", key)
+	}
+	n.send(&messages.Hello{})
+}
+
+func encrypt(challenge uint64) uint64 {
+	var block [8]byte
+	cipher, _ := des.NewCipher(noPasswordKey)
+	binary.BigEndian.PutUint64(block[:], challenge)
+	cipher.Encrypt(block[:], block[:])
+	return binary.BigEndian.Uint64(block[:])
+}
+
+// dial docks a session with a test Newton.
+func dial(t *testing.T) (*Session, *newton) {
+	desktop, newtonEnd := net.Pipe()
+	n := newNewton(t, newtonEnd)
+	type result struct {
+		session *Session
+		err     error
+	}
+	dialed := make(chan result)
+	go func() {
+		session, err := Dial(context.Background(), pipe{desktop}, dock.Config{KeepAlive: -1})
+		dialed <- result{session, err}
+	}()
+	n.dock()
+	r := <-dialed
+	if r.err != nil {
+		t.Fatal(r.err)
+	}
+	if device := r.session.Device(); device == nil || device.Name != "Test" {
+		t.Errorf("got device %+v", device)
+	}
+	return r.session, n
+}
+
+// disconnect ends session from the desktop.
+func disconnect(t *testing.T, session *Session, n *newton) {
+	t.Helper()
+	done := make(chan error)
+	go func() { done <- session.Disconnect(context.Background()) }()
+	n.expect(protocol.DISCONNECT)
+	n.expectClosed()
+	if err := <-done; err != nil || session.Err() != nil {
+		t.Errorf("Disconnect() = %v, session error %v", err, session.Err())
+	}
+}
+
+type storeNames struct {
+	stores []Store
+	err    error
+}
+
+func callStoreNames(ctx context.Context, session *Session) chan storeNames {
+	result := make(chan storeNames, 1)
+	go func() {
+		stores, err := session.StoreNames(ctx)
+		result <- storeNames{stores, err}
+	}()
+	return result
+}
+
+func store(name string) *nsof.Frame {
+	return &nsof.Frame{Slots: []nsof.Slot{{Key: &nsof.Symbol{Value: "name"}, Value: messages.NewString(name)}}}
+}
+
+func TestStoreNames(t *testing.T) {
+	session, n := dial(t)
+	result := callStoreNames(context.Background(), session)
+	n.expect(protocol.GET_STORE_NAMES)
+	n.send(&messages.StoreNames{Stores: []*nsof.Frame{store("Internal"), store("Card")}})
+	r := <-result
+	if r.err != nil {
+		t.Fatal(r.err)
+	}
+	var names []string
+	for _, store := range r.stores {
+		names = append(names, store.Name)
+	}
+	if want := []string{"Internal", "Card"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("got %q, want %q", names, want)
+	}
+	disconnect(t, session, n)
+}
+
+func TestCancel(t *testing.T) {
+	session, n := dial(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	result := callStoreNames(ctx, session)
+	n.expect(protocol.GET_STORE_NAMES)
+	cancel()
+	n.expect(protocol.OPERATION_CANCELED)
+	n.send(&messages.OpCanceledAck{})
+	if r := <-result; !errors.Is(r.err, context.Canceled) {
+		t.Errorf("got %v, want %v", r.err, context.Canceled)
+	}
+	// The session is still up.
+	result = callStoreNames(context.Background(), session)
+	n.expect(protocol.GET_STORE_NAMES)
+	n.send(&messages.StoreNames{Stores: []*nsof.Frame{store("Internal")}})
+	if r := <-result; r.err != nil || len(r.stores) != 1 {
+		t.Errorf("got %v, %v after the cancelation", r.stores, r.err)
+	}
+	disconnect(t, session, n)
+}
+
+func TestCancelTimeout(t *testing.T) {
+	defer func(timeout time.Duration) { cancelTimeout = timeout }(cancelTimeout)
+	cancelTimeout = 100 * time.Millisecond
+	session, n := dial(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	result := callStoreNames(ctx, session)
+	n.expect(protocol.GET_STORE_NAMES)
+	cancel()
+	n.expect(protocol.OPERATION_CANCELED)
+	// Without an acknowledgement, the session is ended.
+	n.expect(protocol.DISCONNECT)
+	n.expectClosed()
+	if r := <-result; !errors.Is(r.err, context.Canceled) {
+		t.Errorf("got %v, want %v", r.err, context.Canceled)
+	}
+	if _, err := session.StoreNames(context.Background()); err != ErrClosed {
+		t.Errorf("got %v after the session ended, want %v", err, ErrClosed)
+	}
+}
+
+func TestNewtonCancels(t *testing.T) {
+	session, n := dial(t)
+	result := callStoreNames(context.Background(), session)
+	n.expect(protocol.GET_STORE_NAMES)
+	n.send(&messages.OperationCanceled{})
+	n.expect(protocol.OP_CANCELED_ACK)
+	if r := <-result; r.err != ErrCanceled {
+		t.Errorf("got %v, want %v", r.err, ErrCanceled)
+	}
+	disconnect(t, session, n)
+}
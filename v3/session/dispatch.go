@@ -0,0 +1,61 @@
+package session
+
+import (
+	"gdcl/v3/protocol"
+	"gdcl/v3/protocol/messages"
+	"log"
+)
+
+// requests are the commands with which the Newton starts an operation,
+// e.g. when the user taps a button in the Connection app.
+var requests = map[protocol.Command]bool{
+	protocol.REQUEST_TO_SYNC:    true,
+	protocol.REQUEST_TO_RESTORE: true,
+	protocol.REQUEST_TO_BROWSE:  true,
+	protocol.REQUEST_TO_INSTALL: true,
+}
+
+// claimed reports whether event is anything but an incoming dock command
+// no layer handles.
+func (session *Session) claimed(event protocol.Event) bool {
+	dockEvent, ok := event.(*protocol.DockEvent)
+	if !ok || dockEvent.Direction != protocol.In {
+		return true
+	}
+	for _, layer := range session.layers {
+		if claimer, ok := layer.(protocol.Claimer); ok && claimer.Claims(dockEvent.Command) {
+			return true
+		}
+	}
+	return false
+}
+
+// unclaimed answers an incoming command no layer handles: operations the
+// Newton requests are canceled, other commands are answered with
+// UNKNOWN_COMMAND.
+func (session *Session) unclaimed(event *protocol.DockEvent) {
+	var reply messages.Message
+	switch {
+	case event.Command == protocol.UNKNOWN_COMMAND:
+		if message, err := messages.Decode(event); err == nil {
+			log.Printf("The Newton does not know %s", message.(*messages.UnknownCommand).BadCommand)
+		}
+		return
+	case event.Command == protocol.OP_CANCELED_ACK:
+		return
+	case event.Command == protocol.OPERATION_CANCELED:
+		reply = &messages.OpCanceledAck{}
+	case requests[event.Command]:
+		log.Printf("Canceling %s, which gdcl does not support here", event.Command)
+		reply = &messages.OperationCanceled{}
+	default:
+		log.Printf("Unknown command %s", event.Command)
+		reply = &messages.UnknownCommand{BadCommand: event.Command}
+	}
+	out, err := messages.Event(reply)
+	if err != nil {
+		session.process(&protocol.ErrorEvent{Err: &protocol.DockError{Err: err}})
+		return
+	}
+	session.events <- out
+}
@@ -0,0 +1,25 @@
+package session
+
+import (
+	"encoding/binary"
+	"gdcl/v3/protocol"
+	"gdcl/v3/protocol/messages"
+	"testing"
+)
+
+func TestUnclaimed(t *testing.T) {
+	session, n := dial(t)
+	unknown := protocol.Command(binary.BigEndian.Uint32([]byte("zzzz")))
+	n.send(&messages.Raw{Cmd: unknown})
+	if message := n.expect(protocol.UNKNOWN_COMMAND).(*messages.UnknownCommand); message.BadCommand != unknown {
+		t.Errorf("got UNKNOWN_COMMAND for %s, want %s", message.BadCommand, unknown)
+	}
+	// Operations started by the Newton are canceled.
+	n.send(&messages.RequestToSync{})
+	n.expect(protocol.OPERATION_CANCELED)
+	n.send(&messages.OpCanceledAck{})
+	// A command the session does not expect now is unclaimed as well.
+	n.send(&messages.StoreNames{})
+	n.expect(protocol.UNKNOWN_COMMAND)
+	disconnect(t, session, n)
+}
@@ -0,0 +1,150 @@
+package session
+
+import (
+	"context"
+	"gdcl/v3/fsm"
+	"gdcl/v3/protocol"
+	"gdcl/v3/protocol/dock"
+	"gdcl/v3/protocol/framing"
+	"gdcl/v3/protocol/mnp"
+	"gdcl/v3/protocol/stream"
+	"gdcl/v3/protocol/transport"
+	"log"
+	"sync"
+	"sync/atomic"
+)
+
+// Session is the connection to one Newton. It owns the event queue and the
+// protocol stack, so any number of sessions can run side by side.
+type Session struct {
+	// Trace is called with every event before it is processed.
+	Trace func(event protocol.Event)
+	// TraceFSM, if set before Run, is called with every step of the
+	// state machines of the layers.
+	TraceFSM       func(step fsm.Step)
+	events         chan protocol.Event
+	transport      transport.Transport
+	transportLayer *transport.Layer
+	layers         []protocol.Layer
+	client         *client
+	calls          sync.Mutex
+	done           chan struct{}
+	err            error
+	device         atomic.Pointer[protocol.DeviceInfo]
+}
+
+// New creates a session on t which docks according to config. Dock packets
+// are carried by MNP unless the transport implements transport.Direct.
+func New(t transport.Transport, config dock.Config) *Session {
+	session := &Session{
+		events:    make(chan protocol.Event, 100),
+		transport: t,
+		done:      make(chan struct{}),
+	}
+	session.transportLayer = transport.New(t, session.events)
+	session.layers = append(session.layers, session.transportLayer)
+	if direct, ok := t.(transport.Direct); ok && direct.Direct() {
+		session.layers = append(session.layers, stream.New(session.events))
+	} else {
+		session.layers = append(session.layers, framing.New(session.events), mnp.New(session.events))
+	}
+	session.layers = append(session.layers, dock.New(session.events, config))
+	return session
+}
+
+// Events is the queue the modules of this session send their events to.
+func (session *Session) Events() chan<- protocol.Event {
+	return session.events
+}
+
+// AddModule adds a layer on top of the dock layer.
+func (session *Session) AddModule(module protocol.Layer) {
+	session.layers = append(session.layers, module)
+}
+
+// Run opens the transport and processes events until the session ends.
+// When ctx is done, the operation in progress is canceled and the session
+// is disconnected. The error is the one that ended the session, if any.
+func (session *Session) Run(ctx context.Context) error {
+	log.Println("Starting event loop")
+	if err := session.transport.Open(ctx); err != nil {
+		return &protocol.TransportError{Err: err}
+	}
+	session.loop(ctx)
+	return session.err
+}
+
+// Device returns the description of the docked Newton, or nil before the
+// Newton has accepted the session.
+func (session *Session) Device() *protocol.DeviceInfo {
+	return session.device.Load()
+}
+
+// Err returns the fatal error which ended the session. It is nil while the
+// session is running and after a clean disconnect.
+func (session *Session) Err() error {
+	select {
+	case <-session.done:
+		return session.err
+	default:
+		return nil
+	}
+}
+
+func (session *Session) loop(ctx context.Context) {
+	defer close(session.done)
+	if session.TraceFSM != nil {
+		for _, layer := range session.layers {
+			if tracer, ok := layer.(protocol.Tracer); ok {
+				tracer.SetTrace(session.TraceFSM)
+			}
+		}
+	}
+	go session.transportLayer.Loop()
+	// After a quit event, keep going until the events queued before it,
+	// e.g. a final LD, have been written.
+	quit := false
+	canceled := ctx.Done()
+	for !quit || len(session.events) > 0 {
+		select {
+		case event := <-session.events:
+			quit = session.process(event) || quit
+		case <-canceled:
+			canceled = nil
+			session.process(&protocol.CancelEvent{})
+			session.process(&protocol.DisconnectEvent{})
+		}
+	}
+	session.transport.Close()
+	log.Println("Event loop complete")
+}
+
+// process passes event to all layers and reports whether it ends the
+// session.
+func (session *Session) process(event protocol.Event) bool {
+	if session.Trace != nil {
+		session.Trace(event)
+	}
+
+	if event, ok := event.(*protocol.ConnectedEvent); ok {
+		session.device.Store(event.Device)
+	}
+
+	claimed := session.claimed(event)
+	for _, layer := range session.layers {
+		layer.Process(event)
+	}
+	if !claimed {
+		session.unclaimed(event.(*protocol.DockEvent))
+	}
+
+	// The first fatal error takes the session down, as cleanly as the
+	// remaining layers allow.
+	if event, ok := event.(*protocol.ErrorEvent); ok && event.Fatal && session.err == nil {
+		session.err = event.Err
+		session.process(&protocol.DisconnectEvent{})
+	}
+
+	_, quit := event.(*protocol.QuitEvent)
+	return quit
+}